@@ -0,0 +1,17 @@
+# Look up an account by its name
+data "pas_account" "by_name" {
+  safe_name = "GCP"
+  name      = "change-account"
+}
+
+# Look up an account by its username and address
+data "pas_account" "by_username" {
+  safe_name = "Linux"
+  username  = "root"
+  address   = "host1.example.com"
+}
+
+# Look up an account with a keyword search
+data "pas_account" "by_search" {
+  search = "svc_backup host2.example.com"
+}
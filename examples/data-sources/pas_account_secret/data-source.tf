@@ -0,0 +1,11 @@
+data "pas_account" "db" {
+  safe_name = "Databases"
+  name      = "app-db-admin"
+}
+
+data "pas_account_secret" "db" {
+  account_id            = data.pas_account.db.id
+  reason                = "Configure the application database connection"
+  ticketing_system_name = "ServiceNow"
+  ticket_id             = "CHG0012345"
+}
@@ -0,0 +1,10 @@
+data "pas_accounts" "linux" {
+  safe_name      = "Linux"
+  search         = "root"
+  modified_since = 1650000000
+  sort           = ["address asc"]
+}
+
+output "linux_root_accounts" {
+  value = { for a in data.pas_accounts.linux.accounts : a.address => a.id }
+}
@@ -1,3 +0,0 @@
-data "scaffolding_data_source" "example" {
-  sample_attribute = "foo"
-}
\ No newline at end of file
@@ -0,0 +1,15 @@
+data "pas_account" "db" {
+  safe_name = "Databases"
+  name      = "app-db-admin"
+}
+
+ephemeral "pas_account_secret" "db" {
+  account_id = data.pas_account.db.id
+  reason     = "Configure the application database connection"
+}
+
+provider "postgresql" {
+  host     = data.pas_account.db.address
+  username = data.pas_account.db.username
+  password = ephemeral.pas_account_secret.db.value
+}
@@ -2,3 +2,56 @@ provider "pas" {
   pas_host  = "cyberark.example.com"
   auth_type = "ldap"
 }
+
+# Privileged Cloud, with the client secret in PAS_CLIENT_SECRET
+provider "pas" {
+  alias    = "cloud"
+  pas_host = "example.privilegecloud.cyberark.cloud"
+
+  auth {
+    method              = "oauth2"
+    identity_tenant_url = "https://abc1234.id.cyberark.cloud"
+    client_id           = "terraform@cyberark.cloud.1234"
+  }
+}
+
+# Certificate based logon to a PVWA that requires mutual TLS
+provider "pas" {
+  alias            = "pki"
+  pas_host         = "cyberark.example.com"
+  auth_type        = "pki"
+  client_cert_file = "/etc/pki/terraform/client.crt"
+  client_key_file  = "/etc/pki/terraform/client.key"
+}
+
+# SAML logon with a response fetched by a helper for every logon
+provider "pas" {
+  alias             = "saml"
+  pas_host          = "cyberark.example.com"
+  auth_type         = "saml"
+  assertion_command = ["pvwa-saml-helper", "--idp", "https://idp.example.com"]
+}
+
+# Logon with a password fetched from a Central Credential Provider
+provider "pas" {
+  alias     = "ccp"
+  pas_host  = "cyberark.example.com"
+  auth_type = "cyberark"
+
+  credential_source {
+    type    = "ccp"
+    ccp_url = "https://ccp.example.com"
+    app_id  = "Terraform"
+    query   = "Safe=Terraform;Object=pvwa-terraform"
+  }
+}
+
+# A PVWA with a certificate from an internal CA, reached through a proxy
+provider "pas" {
+  alias        = "internal"
+  pas_host     = "pvwa.corp.example.com"
+  auth_type    = "ldap"
+  ca_cert_file = "/etc/pki/tls/certs/corp-root-ca.pem"
+  proxy_url    = "http://proxy.corp.example.com:3128"
+  base_path    = "/PasswordVault"
+}
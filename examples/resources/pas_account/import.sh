@@ -0,0 +1,5 @@
+# Accounts can be imported using their account ID
+terraform import pas_account.db 12_34
+
+# or using the safe name and account name separated by a slash
+terraform import pas_account.db "DBSafe/Operating System-UnixSSH-db.example.com-svc_db"
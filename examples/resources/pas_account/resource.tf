@@ -0,0 +1,35 @@
+resource "pas_account" "db" {
+  safe_name   = pas_safe.app.safe_name
+  platform_id = "MySQL"
+  address     = "db.example.com"
+  username    = "app_user"
+
+  # Write-only: never stored in state. Increment secret_version to send a new value.
+  secret         = var.initial_password
+  secret_version = 1
+
+  # Let the CPM rotate the password without Terraform sending it back.
+  secret_lifecycle = "initial_only"
+
+  platform_account_properties = {
+    Port     = "3306"
+    Database = "app"
+  }
+
+  secret_management {
+    automatic_management_enabled = false
+    manual_management_reason     = "Rotated by the application deployment"
+  }
+}
+
+resource "pas_account" "admin" {
+  safe_name   = pas_safe.app.safe_name
+  platform_id = "WinDomain"
+  address     = "example.com"
+  username    = "app_admin"
+
+  remote_machines_access {
+    remote_machines                      = "app1.example.com;app2.example.com"
+    access_restricted_to_remote_machines = true
+  }
+}
@@ -0,0 +1,5 @@
+# AWS IAM user accounts can be imported using their account ID
+terraform import pas_account_aws_iam_user.deploy 12_34
+
+# or using the safe name and account name separated by a slash
+terraform import pas_account_aws_iam_user.deploy AWSSafe/AWS-www.AWS.com-deploy
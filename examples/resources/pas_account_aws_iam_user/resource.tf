@@ -2,4 +2,8 @@ resource "pas_account_aws_iam_user" "aws" {
   safe_name      = "MySafe"
   username       = "iamuser"
   aws_account_id = "123456789012"
+
+  # Write-only: never stored in state. Increment password_version to send a new value.
+  password         = var.initial_password
+  password_version = 1
 }
@@ -0,0 +1,5 @@
+# GCP service accounts can be imported using their account ID
+terraform import pas_account_gcp_service_account.terraform 12_34
+
+# or using the safe name and account name separated by a slash
+terraform import pas_account_gcp_service_account.terraform GCPSafe/terraform@project-id.iam.gserviceaccount.com
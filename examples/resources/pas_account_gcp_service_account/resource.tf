@@ -1,5 +1,12 @@
+resource "google_service_account_key" "terraform" {
+  service_account_id = "projects/project-id/serviceAccounts/terraform@project-id.iam.gserviceaccount.com"
+}
+
 resource "pas_account_gcp_service_account" "gcp_sa" {
   safe_name = "MySafe"
-  username  = "service-account@project-id.iam.gserviceaccount.com"
-  key_id    = "fOaULWiinTfatlEMeUPScRqi9n0oQmBywvtN3jc7"
+  username  = "terraform@project-id.iam.gserviceaccount.com"
+
+  # Write-only: never stored in state. Increment key_json_version to send a new key.
+  key_json         = base64decode(google_service_account_key.terraform.private_key)
+  key_json_version = 1
 }
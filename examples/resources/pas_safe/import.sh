@@ -0,0 +1,2 @@
+# Safes can be imported using their safe URL ID
+terraform import pas_safe.app AppSafe
@@ -0,0 +1,6 @@
+resource "pas_safe" "app" {
+  safe_name                = "AppSafe"
+  description              = "Credentials for the example application"
+  managing_cpm             = "PasswordManager"
+  number_of_days_retention = 7
+}
@@ -0,0 +1,2 @@
+# Safe members can be imported using the safe name and member name separated by a slash
+terraform import pas_safe_member.app_team AppSafe/AppTeam
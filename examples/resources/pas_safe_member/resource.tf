@@ -0,0 +1,10 @@
+resource "pas_safe_member" "app_team" {
+  safe_name         = pas_safe.app.safe_name
+  member_name       = "AppTeam"
+  member_type       = "Group"
+  search_in         = "example.com"
+  permission_preset = "end-user"
+
+  # Explicit permissions override the preset
+  view_safe_members = false
+}
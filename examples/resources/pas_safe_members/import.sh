@@ -0,0 +1,2 @@
+# The members of a safe can be imported using the safe name
+terraform import pas_safe_members.app AppSafe
@@ -0,0 +1,18 @@
+resource "pas_safe_members" "app" {
+  safe_name = pas_safe.app.safe_name
+
+  # The CPM user is added to the safe by the Vault
+  ignored_members = ["PasswordManager"]
+
+  member {
+    member_name = "terraform"
+    permissions = ["list_accounts", "add_accounts", "update_account_content", "update_account_properties", "delete_accounts", "manage_safe", "manage_safe_members", "view_safe_members"]
+  }
+
+  member {
+    member_name = "AppTeam"
+    member_type = "Group"
+    search_in   = "example.com"
+    permissions = ["use_accounts", "retrieve_accounts", "list_accounts"]
+  }
+}
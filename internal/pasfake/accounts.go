@@ -0,0 +1,649 @@
+package pasfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// account is an account in the Vault. Its Accounts API representation is kept
+// as a JSON document so that JSON-patch updates can be applied to it as sent.
+type account struct {
+	doc      map[string]interface{}
+	secret   string
+	versions int
+	links    map[int]linkedAccount
+}
+
+// linkedAccount is an account linked to another one, such as its logon or
+// reconcile account.
+type linkedAccount struct {
+	Safe   string `json:"safe"`
+	Folder string `json:"folder"`
+	Name   string `json:"name"`
+	Index  int    `json:"extraPasswordIndex"`
+}
+
+func (a *account) str(key string) string {
+	s, _ := a.doc[key].(string)
+	return s
+}
+
+func (a *account) properties() map[string]interface{} {
+	p, _ := a.doc["platformAccountProperties"].(map[string]interface{})
+	return p
+}
+
+// setSecret stores a new version of the secret of the account.
+func (a *account) setSecret(secret string) {
+	a.secret = secret
+	a.versions++
+
+	if sm, ok := a.doc["secretManagement"].(map[string]interface{}); ok {
+		sm["lastModifiedTime"] = now()
+	}
+}
+
+// normalize sets the fields of an account that are managed by the Vault,
+// after it is added or updated.
+func (a *account) normalize(id string) {
+	a.doc["id"] = id
+	a.doc["categoryModificationTime"] = now()
+	if _, ok := a.doc["platformAccountProperties"].(map[string]interface{}); !ok {
+		a.doc["platformAccountProperties"] = map[string]interface{}{}
+	}
+	if _, ok := a.doc["secretType"]; !ok {
+		a.doc["secretType"] = "password"
+	}
+
+	sm, ok := a.doc["secretManagement"].(map[string]interface{})
+	if !ok {
+		sm = map[string]interface{}{}
+		a.doc["secretManagement"] = sm
+	}
+	if _, ok := sm["automaticManagementEnabled"]; !ok {
+		sm["automaticManagementEnabled"] = true
+	}
+	if sm["automaticManagementEnabled"] == true {
+		delete(sm, "manualManagementReason")
+	}
+	sm["status"] = "success"
+	if _, ok := sm["lastModifiedTime"]; !ok {
+		sm["lastModifiedTime"] = now()
+	}
+}
+
+// findAccount returns the account with an ID. The caller must hold s.mu.
+func (s *Server) findAccount(w http.ResponseWriter, id string) (*account, bool) {
+	a, ok := s.accounts[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "Account %s was not found.", id)
+	}
+	return a, ok
+}
+
+func (s *Server) addAccount(w http.ResponseWriter, r *http.Request) {
+	var doc map[string]interface{}
+	if !decode(w, r, &doc) {
+		return
+	}
+
+	a := &account{doc: doc, links: map[int]linkedAccount{}}
+
+	if a.str("platformId") == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "platformId is required.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, ok := s.findSafe(w, a.str("safeName"))
+	if !ok {
+		return
+	}
+	doc["safeName"] = sf.SafeName
+
+	if a.str("name") == "" {
+		parts := []string{"Operating System", a.str("platformId")}
+		for _, p := range []string{a.str("address"), a.str("userName")} {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		doc["name"] = strings.Join(parts, "-")
+	}
+
+	for _, other := range s.accounts {
+		if strings.EqualFold(other.str("safeName"), sf.SafeName) && strings.EqualFold(other.str("name"), a.str("name")) {
+			writeError(w, http.StatusConflict, codeConflict, "Account %s already exists in safe %s.", a.str("name"), sf.SafeName)
+			return
+		}
+	}
+
+	if secret := a.str("secret"); secret != "" {
+		a.setSecret(secret)
+	}
+	delete(doc, "secret")
+
+	s.lastID++
+	id := fmt.Sprintf("%d_%d", sf.SafeNumber, s.lastID)
+	a.normalize(id)
+	doc["createdTime"] = now()
+	s.accounts[id] = a
+
+	writeJSON(w, http.StatusCreated, doc)
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.doc)
+}
+
+func (s *Server) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.findAccount(w, id); !ok {
+		return
+	}
+
+	delete(s.accounts, id)
+	writeNoContent(w)
+}
+
+// patchOperation is a JSON-patch operation on an account.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+func (s *Server) updateAccount(w http.ResponseWriter, r *http.Request) {
+	var operations []patchOperation
+	if !decode(w, r, &operations) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	a, ok := s.findAccount(w, id)
+	if !ok {
+		return
+	}
+
+	doc, err := applyPatch(a.doc, operations)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "%s", err)
+		return
+	}
+
+	if _, ok := doc["secret"]; ok {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "The secret of an account cannot be updated with a patch.")
+		return
+	}
+	if doc["id"] != id {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "The id of an account cannot be updated.")
+		return
+	}
+	doc["createdTime"] = a.doc["createdTime"]
+	sf, ok := s.findSafe(w, fmt.Sprint(doc["safeName"]))
+	if !ok {
+		return
+	}
+	doc["safeName"] = sf.SafeName
+
+	a.doc = doc
+	a.normalize(id)
+
+	writeJSON(w, http.StatusOK, a.doc)
+}
+
+// applyPatch returns a copy of doc with JSON-patch operations applied as
+// described in RFC 6902. add sets the value at the path, creating or replacing
+// it, while replace and remove require the path to exist and replace or delete
+// its value. The account itself cannot be patched as a whole.
+func applyPatch(doc map[string]interface{}, operations []patchOperation) (map[string]interface{}, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var patched map[string]interface{}
+	if err := json.Unmarshal(b, &patched); err != nil {
+		return nil, err
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+
+	for _, op := range operations {
+		if op.Path == "" || op.Path == "/" {
+			return nil, fmt.Errorf("the account itself cannot be patched, patch its fields instead")
+		}
+		if !strings.HasPrefix(op.Path, "/") {
+			return nil, fmt.Errorf("path %q is not a JSON pointer", op.Path)
+		}
+
+		segments := strings.Split(op.Path[1:], "/")
+		for i, s := range segments {
+			segments[i] = unescape.Replace(s)
+		}
+
+		parent := patched
+		for _, s := range segments[:len(segments)-1] {
+			next, ok := parent[s].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", op.Path)
+			}
+			parent = next
+		}
+
+		key := segments[len(segments)-1]
+		_, exists := parent[key]
+
+		switch op.Op {
+		case "add":
+			parent[key] = op.Value
+		case "replace":
+			if !exists {
+				return nil, fmt.Errorf("path %q does not exist", op.Path)
+			}
+			parent[key] = op.Value
+		case "remove":
+			if !exists {
+				return nil, fmt.Errorf("path %q does not exist", op.Path)
+			}
+			delete(parent, key)
+		default:
+			return nil, fmt.Errorf("operation %q is not supported", op.Op)
+		}
+	}
+
+	return patched, nil
+}
+
+// accountsPage is a page of the Get Accounts response.
+type accountsPage struct {
+	Value    []map[string]interface{} `json:"value"`
+	Count    int                      `json:"count"`
+	NextLink string                   `json:"nextLink,omitempty"`
+}
+
+func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter, err := parseAccountsFilter(query.Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "%s", err)
+		return
+	}
+
+	offset, limit, err := paging(query, 50, 1000)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "%s", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	words := strings.Fields(strings.ToLower(query.Get("search")))
+	startsWith := strings.EqualFold(query.Get("searchType"), "startswith")
+
+	matches := []*account{}
+	for _, a := range s.sortedAccounts() {
+		if filter(a) && matchesSearch(a, words, startsWith) {
+			matches = append(matches, a)
+		}
+	}
+
+	for i := len(query["sort"]) - 1; i >= 0; i-- {
+		for _, order := range strings.Split(query["sort"][i], ",") {
+			field, direction, _ := strings.Cut(strings.TrimSpace(order), " ")
+			desc := strings.EqualFold(strings.TrimSpace(direction), "desc")
+			sort.SliceStable(matches, func(i, j int) bool {
+				a, b := strings.ToLower(matches[i].str(field)), strings.ToLower(matches[j].str(field))
+				if desc {
+					return a > b
+				}
+				return a < b
+			})
+		}
+	}
+
+	page := accountsPage{Value: []map[string]interface{}{}, Count: len(matches)}
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		page.Value = append(page.Value, matches[i].doc)
+	}
+	if offset+limit < len(matches) {
+		next := r.URL.Query()
+		next.Set("offset", strconv.Itoa(offset+limit))
+		next.Set("limit", strconv.Itoa(limit))
+		page.NextLink = "api/Accounts?" + next.Encode()
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// sortedAccounts returns the accounts in the order they were added. The
+// caller must hold s.mu.
+func (s *Server) sortedAccounts() []*account {
+	list := make([]*account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return accountSequence(list[i]) < accountSequence(list[j])
+	})
+	return list
+}
+
+func accountSequence(a *account) int {
+	_, seq, _ := strings.Cut(a.str("id"), "_")
+	n, _ := strconv.Atoi(seq)
+	return n
+}
+
+// parseAccountsFilter parses the filter of the Get Accounts API, which
+// supports safeName and modificationTime conditions joined by AND.
+func parseAccountsFilter(filter string) (func(*account) bool, error) {
+	conditions := []func(*account) bool{}
+
+	for _, clause := range splitFold(filter, " AND ") {
+		fields := strings.SplitN(strings.TrimSpace(clause), " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("filter %q is invalid", filter)
+		}
+
+		field, op, value := fields[0], strings.ToLower(fields[1]), fields[2]
+		switch {
+		case strings.EqualFold(field, "safeName") && op == "eq":
+			conditions = append(conditions, func(a *account) bool {
+				return strings.EqualFold(a.str("safeName"), value)
+			})
+		case strings.EqualFold(field, "modificationTime") && op == "gte":
+			since, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("filter %q is invalid: %s", filter, err)
+			}
+			conditions = append(conditions, func(a *account) bool {
+				t, _ := a.doc["categoryModificationTime"].(int64)
+				return t >= since
+			})
+		default:
+			return nil, fmt.Errorf("filter %q is not supported", clause)
+		}
+	}
+
+	return func(a *account) bool {
+		for _, c := range conditions {
+			if !c(a) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// splitFold splits s around each instance of sep, ignoring case. It returns
+// nothing for an empty s.
+func splitFold(s, sep string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	parts := []string{}
+	for {
+		i := strings.Index(strings.ToLower(s), strings.ToLower(sep))
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+len(sep):]
+	}
+}
+
+// matchesSearch reports whether every search word appears in the name,
+// username or address of an account.
+func matchesSearch(a *account, words []string, startsWith bool) bool {
+	for _, word := range words {
+		found := false
+		for _, field := range []string{"name", "userName", "address"} {
+			v := strings.ToLower(a.str(field))
+			if startsWith && strings.HasPrefix(v, word) || !startsWith && strings.Contains(v, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// paging returns the offset and limit of a paged request.
+func paging(query map[string][]string, defaultLimit, maxLimit int) (int, int, error) {
+	offset, limit := 0, defaultLimit
+
+	if v := firstValue(query, "offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset %q is invalid", v)
+		}
+		offset = n
+	}
+
+	if v := firstValue(query, "limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return 0, 0, fmt.Errorf("limit %q must be between 1 and %d", v, maxLimit)
+		}
+		limit = n
+	}
+
+	return offset, limit, nil
+}
+
+func firstValue(query map[string][]string, key string) string {
+	if v := query[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+type changeCredentialsRequest struct {
+	NewCredentials string `json:"NewCredentials"`
+}
+
+func (s *Server) changeAccountSecret(w http.ResponseWriter, r *http.Request) {
+	var change changeCredentialsRequest
+	if !decode(w, r, &change) {
+		return
+	}
+
+	if change.NewCredentials == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "NewCredentials is required.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	a.setSecret(change.NewCredentials)
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) retrieveAccountSecret(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.secret)
+}
+
+type secretVersion struct {
+	VersionID    int    `json:"versionID"`
+	IsTemporary  bool   `json:"isTemporary"`
+	ModifiedBy   string `json:"modifiedBy"`
+	ModifiedTime int64  `json:"modifiedTime"`
+}
+
+func (s *Server) listSecretVersions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	versions := []secretVersion{}
+	for v := a.versions; v > 0; v-- {
+		versions = append(versions, secretVersion{VersionID: v, ModifiedBy: s.Username, ModifiedTime: now()})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Versions": versions})
+}
+
+func (s *Server) linkAccount(w http.ResponseWriter, r *http.Request) {
+	var link linkedAccount
+	if !decode(w, r, &link) {
+		return
+	}
+
+	if link.Index < 1 || link.Index > 3 {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "extraPasswordIndex must be 1, 2 or 3.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	found := false
+	for _, other := range s.accounts {
+		if strings.EqualFold(other.str("safeName"), link.Safe) && strings.EqualFold(other.str("name"), link.Name) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, codeNotFound, "Account %s was not found in safe %s.", link.Name, link.Safe)
+		return
+	}
+
+	a.links[link.Index] = link
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) clearLinkedAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	index, _ := strconv.Atoi(r.PathValue("index"))
+	if _, ok := a.links[index]; !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "Account %s has no linked account with index %s.", r.PathValue("id"), r.PathValue("index"))
+		return
+	}
+
+	delete(a.links, index)
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+type keyValue struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+type legacyAccount struct {
+	AccountID          string     `json:"AccountID"`
+	Properties         []keyValue `json:"Properties"`
+	InternalProperties []keyValue `json:"InternalProperties"`
+}
+
+// listAccountsLegacy serves the Get Accounts API of the PVWA v9, which
+// unlike the current API returns the accounts linked to each account.
+func (s *Server) listAccountsLegacy(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	safeName := query.Get("Safe")
+	words := strings.FieldsFunc(strings.ToLower(query.Get("Keywords")), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := []legacyAccount{}
+	for _, a := range s.sortedAccounts() {
+		if safeName != "" && !strings.EqualFold(a.str("safeName"), safeName) || !matchesSearch(a, words, false) {
+			continue
+		}
+
+		legacy := legacyAccount{
+			AccountID: a.str("id"),
+			Properties: []keyValue{
+				{"Safe", a.str("safeName")},
+				{"Folder", "Root"},
+				{"Name", a.str("name")},
+				{"UserName", a.str("userName")},
+				{"Address", a.str("address")},
+				{"PolicyID", a.str("platformId")},
+			},
+			InternalProperties: []keyValue{},
+		}
+
+		keys := []string{}
+		for k := range a.properties() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			legacy.Properties = append(legacy.Properties, keyValue{k, fmt.Sprint(a.properties()[k])})
+		}
+
+		for i := 1; i <= 3; i++ {
+			if link, ok := a.links[i]; ok {
+				legacy.InternalProperties = append(legacy.InternalProperties,
+					keyValue{fmt.Sprintf("ExtraPass%dName", i), link.Name},
+					keyValue{fmt.Sprintf("ExtraPass%dFolder", i), link.Folder},
+					keyValue{fmt.Sprintf("ExtraPass%dSafe", i), link.Safe},
+				)
+			}
+		}
+
+		accounts = append(accounts, legacy)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Count": len(accounts), "accounts": accounts})
+}
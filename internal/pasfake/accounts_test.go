@@ -0,0 +1,201 @@
+package pasfake
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	doc := map[string]interface{}{
+		"id":       "1_1",
+		"name":     "account",
+		"userName": "svc",
+		"platformAccountProperties": map[string]interface{}{
+			"Port":     "22",
+			"Location": "DC1",
+		},
+		"secretManagement": map[string]interface{}{
+			"automaticManagementEnabled": true,
+		},
+	}
+
+	patched, err := applyPatch(doc, []patchOperation{
+		{Op: "replace", Path: "/userName", Value: "svc2"},
+		{Op: "add", Path: "/platformAccountProperties/Owner~1Team", Value: "platform"},
+		{Op: "replace", Path: "/platformAccountProperties/Port", Value: "2222"},
+		{Op: "remove", Path: "/platformAccountProperties/Location"},
+		{Op: "replace", Path: "/secretManagement/automaticManagementEnabled", Value: false},
+		{Op: "add", Path: "/address", Value: "host.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"id":       "1_1",
+		"name":     "account",
+		"userName": "svc2",
+		"address":  "host.example.com",
+		"platformAccountProperties": map[string]interface{}{
+			"Port":       "2222",
+			"Owner/Team": "platform",
+		},
+		"secretManagement": map[string]interface{}{
+			"automaticManagementEnabled": false,
+		},
+	}
+	if !reflect.DeepEqual(patched, want) {
+		t.Errorf("got %#v, want %#v", patched, want)
+	}
+
+	patched, err = applyPatch(doc, []patchOperation{
+		{Op: "replace", Path: "/platformAccountProperties", Value: map[string]interface{}{"Port": "2222"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props := patched["platformAccountProperties"]; !reflect.DeepEqual(props, map[string]interface{}{"Port": "2222"}) {
+		t.Errorf("replacing the properties gave %#v, want only the new properties", props)
+	}
+
+	patched, err = applyPatch(doc, []patchOperation{
+		{Op: "remove", Path: "/platformAccountProperties"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := patched["platformAccountProperties"]; ok {
+		t.Error("removing the properties left them in place")
+	}
+
+	if doc["userName"] != "svc" {
+		t.Error("applyPatch modified the original document")
+	}
+
+	for _, op := range []patchOperation{
+		{Op: "move", Path: "/name", Value: "other"},
+		{Op: "remove", Path: "/missing"},
+		{Op: "replace", Path: "/missing", Value: "x"},
+		{Op: "replace", Path: "/", Value: map[string]interface{}{"userName": "svc2"}},
+		{Op: "add", Path: "/missing/child", Value: "x"},
+	} {
+		if _, err := applyPatch(doc, []patchOperation{op}); err == nil {
+			t.Errorf("expected %s of %s to fail", op.Op, op.Path)
+		}
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	c := newTestSession(t)
+
+	if status := c.do(http.MethodPost, "/api/Safes", map[string]interface{}{"safeName": "Linux"}, nil); status != http.StatusCreated {
+		t.Fatalf("adding a safe failed with status %d", status)
+	}
+
+	if status := c.do(http.MethodPost, "/api/Accounts", map[string]interface{}{"safeName": "Missing", "platformId": "UnixSSH"}, nil); status != http.StatusNotFound {
+		t.Errorf("adding an account to a missing safe returned status %d, want 404", status)
+	}
+
+	ids := []string{}
+	for _, username := range []string{"svc_b", "svc_a", "root"} {
+		var account map[string]interface{}
+		status := c.do(http.MethodPost, "/api/Accounts", map[string]interface{}{
+			"safeName":   "linux",
+			"platformId": "UnixSSH",
+			"address":    "host.example.com",
+			"userName":   username,
+			"secret":     "Passw0rd",
+		}, &account)
+		if status != http.StatusCreated {
+			t.Fatalf("adding account %s failed with status %d", username, status)
+		}
+		if account["secret"] != nil || account["safeName"] != "Linux" || account["name"] != "Operating System-UnixSSH-host.example.com-"+username {
+			t.Errorf("unexpected account %v", account)
+		}
+		ids = append(ids, account["id"].(string))
+	}
+
+	var page accountsPage
+	c.do(http.MethodGet, "/api/Accounts?search=svc&filter=safeName%20eq%20Linux&sort=userName%20asc&limit=1", nil, &page)
+	if page.Count != 2 || len(page.Value) != 1 || page.Value[0]["userName"] != "svc_a" || page.NextLink == "" {
+		t.Errorf("unexpected first page %+v", page)
+	}
+	page = accountsPage{}
+	c.do(http.MethodGet, "/api/Accounts?search=svc&filter=safeName%20eq%20Linux&sort=userName%20asc&limit=1&offset=1", nil, &page)
+	if page.Count != 2 || len(page.Value) != 1 || page.Value[0]["userName"] != "svc_b" || page.NextLink != "" {
+		t.Errorf("unexpected second page %+v", page)
+	}
+	if status := c.do(http.MethodGet, "/api/Accounts?filter=platformId%20eq%20UnixSSH", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("unsupported filter returned status %d, want 400", status)
+	}
+
+	id := ids[0]
+
+	var secret string
+	c.do(http.MethodPost, "/api/Accounts/"+id+"/Password/Update", map[string]string{"NewCredentials": "Changed"}, nil)
+	c.do(http.MethodPost, "/api/Accounts/"+id+"/Password/Retrieve", map[string]string{"reason": "test"}, &secret)
+	if secret != "Changed" {
+		t.Errorf("retrieved secret %q, want %q", secret, "Changed")
+	}
+
+	var versions struct {
+		Versions []secretVersion
+	}
+	c.do(http.MethodGet, "/api/Accounts/"+id+"/Secret/Versions", nil, &versions)
+	if len(versions.Versions) != 2 || versions.Versions[0].VersionID != 2 {
+		t.Errorf("unexpected secret versions %+v", versions)
+	}
+
+	var account map[string]interface{}
+	c.do(http.MethodPatch, "/api/Accounts/"+id, []patchOperation{
+		{Op: "add", Path: "/platformAccountProperties", Value: map[string]interface{}{"Port": "22"}},
+		{Op: "replace", Path: "/secretManagement", Value: map[string]interface{}{"automaticManagementEnabled": false, "manualManagementReason": "test"}},
+	}, &account)
+	if account["platformAccountProperties"].(map[string]interface{})["Port"] != "22" || account["secretManagement"].(map[string]interface{})["manualManagementReason"] != "test" {
+		t.Errorf("unexpected patched account %v", account)
+	}
+	if status := c.do(http.MethodPatch, "/api/Accounts/"+id, []patchOperation{{Op: "replace", Path: "/id", Value: "2_2"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("changing the ID returned status %d, want 400", status)
+	}
+
+	link := linkedAccount{Safe: "Linux", Folder: "Root", Name: "Operating System-UnixSSH-host.example.com-root", Index: 3}
+	if status := c.do(http.MethodPost, "/api/Accounts/"+id+"/LinkAccount", link, nil); status != http.StatusOK {
+		t.Errorf("linking an account failed with status %d", status)
+	}
+	link.Name = "missing"
+	if status := c.do(http.MethodPost, "/api/Accounts/"+id+"/LinkAccount", link, nil); status != http.StatusNotFound {
+		t.Errorf("linking a missing account returned status %d, want 404", status)
+	}
+
+	var legacy struct {
+		Count    int
+		Accounts []legacyAccount
+	}
+	c.do(http.MethodGet, "/WebServices/PIMServices.svc/Accounts?Keywords=svc_b&Safe=Linux", nil, &legacy)
+	if legacy.Count != 1 || legacy.Accounts[0].AccountID != id {
+		t.Fatalf("unexpected legacy search result %+v", legacy)
+	}
+	want := []keyValue{
+		{"ExtraPass3Name", "Operating System-UnixSSH-host.example.com-root"},
+		{"ExtraPass3Folder", "Root"},
+		{"ExtraPass3Safe", "Linux"},
+	}
+	if !reflect.DeepEqual(legacy.Accounts[0].InternalProperties, want) {
+		t.Errorf("got internal properties %+v, want %+v", legacy.Accounts[0].InternalProperties, want)
+	}
+
+	if status := c.do(http.MethodDelete, "/api/Accounts/"+id+"/LinkAccount/3", nil, nil); status != http.StatusOK {
+		t.Errorf("clearing a linked account failed with status %d", status)
+	}
+	if status := c.do(http.MethodDelete, "/api/Accounts/"+id+"/LinkAccount/3", nil, nil); status != http.StatusNotFound {
+		t.Errorf("clearing a missing linked account returned status %d, want 404", status)
+	}
+
+	if status := c.do(http.MethodDelete, "/api/Accounts/"+id, nil, nil); status != http.StatusNoContent {
+		t.Errorf("deleting an account failed with status %d", status)
+	}
+	if status := c.do(http.MethodGet, "/api/Accounts/"+id, nil, nil); status != http.StatusNotFound {
+		t.Errorf("getting a deleted account returned status %d, want 404", status)
+	}
+}
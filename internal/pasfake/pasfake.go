@@ -0,0 +1,234 @@
+// Package pasfake is an in-memory stand-in for the CyberArk PAS Password Vault
+// Web Access (PVWA) REST API. It serves the parts of the API the provider uses,
+// so that resources can be tested through their whole lifecycle without a
+// Vault.
+package pasfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BasePath is the path the fake serves the PVWA API under.
+const BasePath = "/PasswordVault"
+
+// Error codes returned by the fake. The provider only relies on the codes
+// for logon failures and RADIUS challenges; the rest identify the failure in
+// test output.
+const (
+	codeAuthenticationFailed = "ITATS004E"
+	codeInvalidParameters    = "PASWS167E"
+	codeUnauthorized         = "PASWS006E"
+	codeNotFound             = "PASWS013E"
+	codeConflict             = "PASWS027E"
+)
+
+// Server is a fake PVWA served over TLS by an httptest.Server. It accepts
+// CyberArk, LDAP and RADIUS logons with Username and Password, and keeps
+// safes, safe members and accounts in memory until it is closed.
+type Server struct {
+	*httptest.Server
+
+	Username string
+	Password string
+
+	mu       sync.Mutex
+	tokens   map[string]bool
+	safes    map[string]*safe
+	accounts map[string]*account
+	lastSafe int64
+	lastID   int
+}
+
+// NewServer starts a fake PVWA that accepts logons with username and
+// password. The caller should call Close when finished, to shut it down.
+func NewServer(username, password string) *Server {
+	s := &Server{
+		Username: username,
+		Password: password,
+		tokens:   map[string]bool{},
+		safes:    map[string]*safe{},
+		accounts: map[string]*account{},
+	}
+
+	s.Server = httptest.NewTLSServer(s.handler())
+	return s
+}
+
+// Host returns the host and port of the server, as expected by pas_host.
+func (s *Server) Host() string {
+	u, _ := url.Parse(s.URL)
+	return u.Host
+}
+
+// CACertPEM returns the PEM encoded certificate of the server, to be trusted
+// by clients.
+func (s *Server) CACertPEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	routes := map[string]http.HandlerFunc{
+		"POST /api/Auth/{type}/Logon": s.logon,
+		"POST /api/Auth/Logoff":       s.logoff,
+
+		"POST /api/Accounts":                            s.addAccount,
+		"GET /api/Accounts":                             s.listAccounts,
+		"GET /api/Accounts/{id}":                        s.getAccount,
+		"PATCH /api/Accounts/{id}":                      s.updateAccount,
+		"DELETE /api/Accounts/{id}":                     s.deleteAccount,
+		"POST /api/Accounts/{id}/Password/Update":       s.changeAccountSecret,
+		"POST /api/Accounts/{id}/Password/Retrieve":     s.retrieveAccountSecret,
+		"GET /api/Accounts/{id}/Secret/Versions":        s.listSecretVersions,
+		"POST /api/Accounts/{id}/LinkAccount":           s.linkAccount,
+		"DELETE /api/Accounts/{id}/LinkAccount/{index}": s.clearLinkedAccount,
+		"GET /WebServices/PIMServices.svc/Accounts":     s.listAccountsLegacy,
+		"GET /WebServices/PIMServices.svc/User":         s.getLogonUser,
+		"POST /api/Safes":                               s.addSafe,
+		"GET /api/Safes/{safe}":                         s.getSafe,
+		"PUT /api/Safes/{safe}":                         s.updateSafe,
+		"DELETE /api/Safes/{safe}":                      s.deleteSafe,
+		"POST /api/Safes/{safe}/Members":                s.addSafeMember,
+		"GET /api/Safes/{safe}/Members":                 s.listSafeMembers,
+		"GET /api/Safes/{safe}/Members/{member}":        s.getSafeMember,
+		"PUT /api/Safes/{safe}/Members/{member}":        s.updateSafeMember,
+		"DELETE /api/Safes/{safe}/Members/{member}":     s.deleteSafeMember,
+		"POST /api/Safes/{safe}/members":                s.addSafeMember,
+		"GET /api/Safes/{safe}/members":                 s.listSafeMembers,
+		"GET /api/Safes/{safe}/members/{member}":        s.getSafeMember,
+		"PUT /api/Safes/{safe}/members/{member}":        s.updateSafeMember,
+		"DELETE /api/Safes/{safe}/members/{member}":     s.deleteSafeMember,
+	}
+
+	for pattern, h := range routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		if strings.HasPrefix(path, "/api/Auth/") {
+			mux.Handle(method+" "+BasePath+path, h)
+			continue
+		}
+		mux.Handle(method+" "+BasePath+path, s.authorized(h))
+	}
+
+	return mux
+}
+
+// authorized rejects requests that do not carry the token of an open session.
+func (s *Server) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		ok := s.tokens[r.Header.Get("Authorization")]
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "The session token is missing, invalid or expired.")
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+type logonRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s *Server) logon(w http.ResponseWriter, r *http.Request) {
+	switch strings.ToLower(r.PathValue("type")) {
+	case "cyberark", "ldap", "radius":
+	default:
+		writeError(w, http.StatusNotFound, codeNotFound, "Authentication type %s is not supported.", r.PathValue("type"))
+		return
+	}
+
+	var logon logonRequest
+	if !decode(w, r, &logon) {
+		return
+	}
+
+	if !strings.EqualFold(logon.Username, s.Username) || logon.Password != s.Password {
+		writeError(w, http.StatusForbidden, codeAuthenticationFailed, "Authentication failure for User [%s].", logon.Username)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInvalidParameters, "%s", err)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, token)
+}
+
+func (s *Server) logoff(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	delete(s.tokens, r.Header.Get("Authorization"))
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// getLogonUser returns the details of the logged on user. The fake only has
+// one user.
+func (s *Server) getLogonUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"UserName":  s.Username,
+		"FirstName": "",
+		"LastName":  "",
+		"Email":     "",
+		"Expired":   false,
+		"Disabled":  false,
+		"Source":    "Internal",
+	})
+}
+
+// now returns the current time in Unix time, which the PVWA uses for all
+// timestamps.
+func now() int64 {
+	return time.Now().Unix()
+}
+
+// decode decodes the JSON body of a request into v, writing an error response
+// and returning false if it cannot.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "The request body is invalid: %s", err)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeNoContent(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeError writes an error in the format used by the PVWA.
+func writeError(w http.ResponseWriter, status int, code, format string, a ...interface{}) {
+	writeJSON(w, status, map[string]string{
+		"ErrorCode":    code,
+		"ErrorMessage": fmt.Sprintf(format, a...),
+	})
+}
@@ -0,0 +1,119 @@
+package pasfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// testSession is a client of a fake PVWA that has logged on.
+type testSession struct {
+	t      *testing.T
+	server *Server
+	token  string
+}
+
+func newTestSession(t *testing.T) *testSession {
+	t.Helper()
+
+	s := NewServer("terraform", "Passw0rd")
+	t.Cleanup(s.Close)
+
+	session := &testSession{t: t, server: s}
+
+	var token string
+	if status := session.do(http.MethodPost, "/api/Auth/CyberArk/Logon", map[string]string{"username": "Terraform", "password": "Passw0rd"}, &token); status != http.StatusOK {
+		t.Fatalf("logon failed with status %d", status)
+	}
+	session.token = token
+
+	return session
+}
+
+// do sends a request to the fake and decodes the response into out. It
+// returns the status of the response.
+func (c *testSession) do(method, path string, body, out interface{}) int {
+	c.t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, c.server.URL+BasePath+path, &reqBody)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+
+	resp, err := c.server.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		c.t.Errorf("%s %s: Content-Type is %q", method, path, ct)
+	}
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: %s", method, path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestLogon(t *testing.T) {
+	c := newTestSession(t)
+
+	if status := c.do(http.MethodGet, "/api/Accounts", nil, nil); status != http.StatusOK {
+		t.Errorf("request with a session token failed with status %d", status)
+	}
+
+	var user map[string]interface{}
+	c.do(http.MethodGet, "/WebServices/PIMServices.svc/User", nil, &user)
+	if user["UserName"] != "terraform" {
+		t.Errorf("unexpected logged on user %v", user)
+	}
+
+	if status := c.do(http.MethodPost, "/api/Auth/Logoff", nil, nil); status != http.StatusOK {
+		t.Errorf("logoff failed with status %d", status)
+	}
+	if status := c.do(http.MethodGet, "/api/Accounts", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("request after logoff returned status %d, want 401", status)
+	}
+
+	c.token = ""
+	for _, logon := range []struct {
+		authType, password string
+		want               int
+	}{
+		{"LDAP", "Passw0rd", http.StatusOK},
+		{"cyberark", "wrong", http.StatusForbidden},
+		{"oauth2", "Passw0rd", http.StatusNotFound},
+	} {
+		if status := c.do(http.MethodPost, "/api/Auth/"+logon.authType+"/Logon", map[string]string{"username": "terraform", "password": logon.password}, nil); status != logon.want {
+			t.Errorf("%s logon with password %q returned status %d, want %d", logon.authType, logon.password, status, logon.want)
+		}
+	}
+}
+
+func TestCACertPEM(t *testing.T) {
+	s := NewServer("terraform", "Passw0rd")
+	defer s.Close()
+
+	if !bytes.HasPrefix([]byte(s.CACertPEM()), []byte("-----BEGIN CERTIFICATE-----")) {
+		t.Errorf("CACertPEM returned %q", s.CACertPEM())
+	}
+	if s.Host() == "" || s.Host() == s.URL {
+		t.Errorf("Host returned %q for %s", s.Host(), s.URL)
+	}
+}
@@ -0,0 +1,462 @@
+package pasfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// safe is a safe in the Vault, in the representation of the Safes API.
+type safe struct {
+	SafeURLID                 string `json:"safeUrlId"`
+	SafeName                  string `json:"safeName"`
+	SafeNumber                int64  `json:"safeNumber"`
+	Description               string `json:"description"`
+	Location                  string `json:"location"`
+	OLACEnabled               bool   `json:"olacEnabled"`
+	ManagingCPM               string `json:"managingCPM"`
+	NumberOfVersionsRetention *int   `json:"numberOfVersionsRetention"`
+	NumberOfDaysRetention     *int   `json:"numberOfDaysRetention"`
+	AutoPurgeEnabled          bool   `json:"autoPurgeEnabled"`
+	CreationTime              int64  `json:"creationTime"`
+	LastModificationTime      int64  `json:"lastModificationTime"`
+
+	members    map[string]*safeMember
+	lastMember int64
+}
+
+// safeRequest is the body of the Add Safe and Update Safe APIs. JSON
+// decoding ignores case, so it accepts both the camel case field names of
+// Add Safe and the capitalized ones of Update Safe.
+type safeRequest struct {
+	SafeName                  *string `json:"safeName"`
+	Description               *string `json:"description"`
+	Location                  *string `json:"location"`
+	OLACEnabled               *bool   `json:"olacEnabled"`
+	ManagingCPM               *string `json:"managingCPM"`
+	NumberOfVersionsRetention *int    `json:"numberOfVersionsRetention"`
+	NumberOfDaysRetention     *int    `json:"numberOfDaysRetention"`
+	AutoPurgeEnabled          *bool   `json:"autoPurgeEnabled"`
+}
+
+// safeMember is a member of a safe, in the representation of the Safe
+// Members API.
+type safeMember struct {
+	SafeURLID                 string          `json:"safeUrlId"`
+	SafeName                  string          `json:"safeName"`
+	SafeNumber                int64           `json:"safeNumber"`
+	MemberID                  int64           `json:"memberId"`
+	MemberName                string          `json:"memberName"`
+	MemberType                string          `json:"memberType"`
+	MembershipExpirationDate  *int64          `json:"membershipExpirationDate"`
+	IsExpiredMembershipEnable bool            `json:"isExpiredMembershipEnable"`
+	IsPredefinedUser          bool            `json:"isPredefinedUser"`
+	IsReadOnly                bool            `json:"isReadOnly"`
+	Permissions               map[string]bool `json:"permissions"`
+}
+
+// safeMemberRequest is the body of the Add Safe Member and Update Safe Member
+// APIs.
+type safeMemberRequest struct {
+	MemberName               string          `json:"memberName"`
+	MemberType               string          `json:"memberType"`
+	SearchIn                 string          `json:"searchIn"`
+	MembershipExpirationDate *int64          `json:"membershipExpirationDate"`
+	Permissions              map[string]bool `json:"permissions"`
+}
+
+// safeMemberPermissions are the permissions of a safe member in the Safe
+// Members API.
+var safeMemberPermissions = []string{
+	"useAccounts", "retrieveAccounts", "listAccounts", "addAccounts", "updateAccountContent",
+	"updateAccountProperties", "initiateCPMAccountManagementOperations", "specifyNextAccountContent",
+	"renameAccounts", "deleteAccounts", "unlockAccounts", "manageSafe", "manageSafeMembers",
+	"backupSafe", "viewAuditLog", "viewSafeMembers", "accessWithoutConfirmation", "createFolders",
+	"deleteFolders", "moveAccountsAndFolders", "requestsAuthorizationLevel1", "requestsAuthorizationLevel2",
+}
+
+// permissions returns the full permission set of a member, with the given
+// permissions set and the rest false.
+func permissions(given map[string]bool) map[string]bool {
+	p := make(map[string]bool, len(safeMemberPermissions))
+	for _, name := range safeMemberPermissions {
+		p[name] = given[name]
+	}
+	return p
+}
+
+// allPermissions returns every permission except the authorization levels.
+func allPermissions() map[string]bool {
+	p := map[string]bool{}
+	for _, name := range safeMemberPermissions {
+		p[name] = !strings.HasPrefix(name, "requestsAuthorizationLevel")
+	}
+	return p
+}
+
+// findSafe returns the safe with a name or URL ID. The caller must hold s.mu.
+func (s *Server) findSafe(w http.ResponseWriter, name string) (*safe, bool) {
+	if sf, ok := s.safes[strings.ToLower(name)]; ok {
+		return sf, true
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		if sf, ok := s.safes[strings.ToLower(unescaped)]; ok {
+			return sf, true
+		}
+	}
+
+	writeError(w, http.StatusNotFound, codeNotFound, "Safe %s was not found.", name)
+	return nil, false
+}
+
+// addMember adds a member to a safe. The caller must hold s.mu.
+func (sf *safe) addMember(name, memberType string, expiration *int64, predefined bool, p map[string]bool) *safeMember {
+	sf.lastMember++
+	m := &safeMember{
+		SafeURLID:                sf.SafeURLID,
+		SafeName:                 sf.SafeName,
+		SafeNumber:               sf.SafeNumber,
+		MemberID:                 sf.lastMember,
+		MemberName:               name,
+		MemberType:               memberType,
+		MembershipExpirationDate: expiration,
+		IsPredefinedUser:         predefined,
+		Permissions:              permissions(p),
+	}
+	sf.members[strings.ToLower(name)] = m
+	return m
+}
+
+func (s *Server) addSafe(w http.ResponseWriter, r *http.Request) {
+	var req safeRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.SafeName == nil || *req.SafeName == "" || len(*req.SafeName) > 28 {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "safeName must be between 1 and 28 characters long.")
+		return
+	}
+	if req.NumberOfVersionsRetention != nil && req.NumberOfDaysRetention != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "Only one of numberOfVersionsRetention and numberOfDaysRetention may be set.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.safes[strings.ToLower(*req.SafeName)]; ok {
+		writeError(w, http.StatusConflict, codeConflict, "Safe %s already exists.", *req.SafeName)
+		return
+	}
+
+	s.lastSafe++
+	sf := &safe{
+		SafeName:     *req.SafeName,
+		SafeNumber:   s.lastSafe,
+		Location:     `\`,
+		CreationTime: now(),
+		members:      map[string]*safeMember{},
+	}
+	sf.update(req)
+	if sf.NumberOfVersionsRetention == nil && sf.NumberOfDaysRetention == nil {
+		days := 7
+		sf.NumberOfDaysRetention = &days
+	}
+
+	sf.addMember("Master", "User", nil, true, allPermissions())
+	sf.addMember(s.Username, "User", nil, false, allPermissions())
+	s.safes[strings.ToLower(sf.SafeName)] = sf
+
+	writeJSON(w, http.StatusCreated, sf)
+}
+
+// update applies the settings given in an Add Safe or Update Safe request.
+func (sf *safe) update(req safeRequest) {
+	if req.SafeName != nil {
+		sf.SafeName = *req.SafeName
+		sf.SafeURLID = url.PathEscape(sf.SafeName)
+	}
+	if req.Description != nil {
+		sf.Description = *req.Description
+	}
+	if req.Location != nil {
+		sf.Location = *req.Location
+	}
+	if req.OLACEnabled != nil {
+		sf.OLACEnabled = *req.OLACEnabled
+	}
+	if req.ManagingCPM != nil {
+		sf.ManagingCPM = *req.ManagingCPM
+	}
+	if req.NumberOfVersionsRetention != nil {
+		sf.NumberOfVersionsRetention = req.NumberOfVersionsRetention
+		sf.NumberOfDaysRetention = nil
+	}
+	if req.NumberOfDaysRetention != nil {
+		sf.NumberOfDaysRetention = req.NumberOfDaysRetention
+		sf.NumberOfVersionsRetention = nil
+	}
+	if req.AutoPurgeEnabled != nil {
+		sf.AutoPurgeEnabled = *req.AutoPurgeEnabled
+	}
+
+	sf.LastModificationTime = now()
+	for _, m := range sf.members {
+		m.SafeName = sf.SafeName
+		m.SafeURLID = sf.SafeURLID
+	}
+}
+
+func (s *Server) getSafe(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, ok := s.findSafe(w, r.PathValue("safe"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sf)
+}
+
+func (s *Server) updateSafe(w http.ResponseWriter, r *http.Request) {
+	var req safeRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.NumberOfVersionsRetention != nil && req.NumberOfDaysRetention != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "Only one of NumberOfVersionsRetention and NumberOfDaysRetention may be set.")
+		return
+	}
+	if req.AutoPurgeEnabled != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "AutoPurgeEnabled can only be set when a safe is added.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, ok := s.findSafe(w, r.PathValue("safe"))
+	if !ok {
+		return
+	}
+
+	if req.OLACEnabled != nil && sf.OLACEnabled && !*req.OLACEnabled {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "Object Level Access Control cannot be disabled.")
+		return
+	}
+
+	oldName := sf.SafeName
+	if req.SafeName != nil && !strings.EqualFold(*req.SafeName, oldName) {
+		if _, ok := s.safes[strings.ToLower(*req.SafeName)]; ok {
+			writeError(w, http.StatusConflict, codeConflict, "Safe %s already exists.", *req.SafeName)
+			return
+		}
+	}
+
+	delete(s.safes, strings.ToLower(oldName))
+	sf.update(req)
+	s.safes[strings.ToLower(sf.SafeName)] = sf
+
+	for _, a := range s.accounts {
+		if strings.EqualFold(a.str("safeName"), oldName) {
+			a.doc["safeName"] = sf.SafeName
+		}
+	}
+
+	writeJSON(w, http.StatusOK, sf)
+}
+
+func (s *Server) deleteSafe(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, ok := s.findSafe(w, r.PathValue("safe"))
+	if !ok {
+		return
+	}
+
+	for _, a := range s.accounts {
+		if strings.EqualFold(a.str("safeName"), sf.SafeName) {
+			writeError(w, http.StatusConflict, codeConflict, "Safe %s cannot be deleted because it contains accounts.", sf.SafeName)
+			return
+		}
+	}
+
+	delete(s.safes, strings.ToLower(sf.SafeName))
+	writeNoContent(w)
+}
+
+// findSafeMember returns a member of a safe. The caller must hold s.mu.
+func (s *Server) findSafeMember(w http.ResponseWriter, r *http.Request) (*safe, *safeMember, bool) {
+	sf, ok := s.findSafe(w, r.PathValue("safe"))
+	if !ok {
+		return nil, nil, false
+	}
+
+	m, ok := sf.members[strings.ToLower(r.PathValue("member"))]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "Member %s was not found in safe %s.", r.PathValue("member"), sf.SafeName)
+		return nil, nil, false
+	}
+
+	return sf, m, true
+}
+
+func (s *Server) addSafeMember(w http.ResponseWriter, r *http.Request) {
+	var req safeMemberRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.MemberName == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "memberName is required.")
+		return
+	}
+	if req.MemberType == "" {
+		req.MemberType = "User"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, ok := s.findSafe(w, r.PathValue("safe"))
+	if !ok {
+		return
+	}
+
+	if _, ok := sf.members[strings.ToLower(req.MemberName)]; ok {
+		writeError(w, http.StatusConflict, codeConflict, "Member %s is already a member of safe %s.", req.MemberName, sf.SafeName)
+		return
+	}
+
+	m := sf.addMember(req.MemberName, req.MemberType, req.MembershipExpirationDate, false, req.Permissions)
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// safeMembersPage is a page of the Get Safe Members response.
+type safeMembersPage struct {
+	Value []*safeMember `json:"value"`
+	Count int           `json:"count"`
+}
+
+func (s *Server) listSafeMembers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, limit, err := paging(query, 25, 1000)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "%s", err)
+		return
+	}
+
+	includePredefined := false
+	for _, clause := range splitFold(query.Get("filter"), " AND ") {
+		if strings.EqualFold(strings.Join(strings.Fields(clause), " "), "includePredefinedUsers eq true") {
+			includePredefined = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, ok := s.findSafe(w, r.PathValue("safe"))
+	if !ok {
+		return
+	}
+
+	members := []*safeMember{}
+	for _, m := range sf.members {
+		if includePredefined || !m.IsPredefinedUser {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].MemberID < members[j].MemberID })
+
+	page := safeMembersPage{Value: []*safeMember{}, Count: len(members)}
+	for i := offset; i < len(members) && i < offset+limit; i++ {
+		page.Value = append(page.Value, members[i])
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) getSafeMember(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, m, ok := s.findSafeMember(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) updateSafeMember(w http.ResponseWriter, r *http.Request) {
+	var raw json.RawMessage
+	if !decode(w, r, &raw) {
+		return
+	}
+
+	var req safeMemberRequest
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(raw, &req)
+	if err == nil {
+		err = json.Unmarshal(raw, &fields)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "The request body is invalid: %s", err)
+		return
+	}
+
+	// As with the PVWA, a membership expiration date that is not sent is
+	// left unchanged, and null clears it.
+	expirationSent := false
+	for field := range fields {
+		if strings.EqualFold(field, "membershipExpirationDate") {
+			expirationSent = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, m, ok := s.findSafeMember(w, r)
+	if !ok {
+		return
+	}
+
+	if m.IsPredefinedUser {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "The permissions of predefined member %s cannot be changed.", m.MemberName)
+		return
+	}
+
+	if expirationSent {
+		m.MembershipExpirationDate = req.MembershipExpirationDate
+	}
+	m.Permissions = permissions(req.Permissions)
+
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) deleteSafeMember(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sf, m, ok := s.findSafeMember(w, r)
+	if !ok {
+		return
+	}
+
+	if m.IsPredefinedUser {
+		writeError(w, http.StatusBadRequest, codeInvalidParameters, "Predefined member %s cannot be removed.", m.MemberName)
+		return
+	}
+
+	delete(sf.members, strings.ToLower(m.MemberName))
+	writeNoContent(w)
+}
@@ -0,0 +1,109 @@
+package pasfake
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSafes(t *testing.T) {
+	c := newTestSession(t)
+
+	var sf safe
+	if status := c.do(http.MethodPost, "/api/Safes", map[string]interface{}{"safeName": "App Safe", "numberOfVersionsRetention": 5}, &sf); status != http.StatusCreated {
+		t.Fatalf("adding a safe failed with status %d", status)
+	}
+	if sf.SafeURLID != "App%20Safe" || sf.Location != `\` || *sf.NumberOfVersionsRetention != 5 || sf.NumberOfDaysRetention != nil {
+		t.Errorf("unexpected safe %+v", sf)
+	}
+
+	if status := c.do(http.MethodPost, "/api/Safes", map[string]interface{}{"safeName": "app safe"}, nil); status != http.StatusConflict {
+		t.Errorf("adding a duplicate safe returned status %d, want 409", status)
+	}
+
+	c.do(http.MethodPost, "/api/Accounts", map[string]interface{}{"safeName": "App Safe", "platformId": "UnixSSH", "userName": "svc"}, nil)
+
+	if status := c.do(http.MethodPut, "/api/Safes/App%2520Safe", map[string]interface{}{"SafeName": "Renamed", "NumberOfDaysRetention": 7}, &sf); status != http.StatusOK {
+		t.Fatalf("updating a safe failed with status %d", status)
+	}
+	if sf.SafeName != "Renamed" || sf.SafeURLID != "Renamed" || sf.NumberOfVersionsRetention != nil || *sf.NumberOfDaysRetention != 7 {
+		t.Errorf("unexpected updated safe %+v", sf)
+	}
+	if status := c.do(http.MethodGet, "/api/Safes/App%20Safe", nil, nil); status != http.StatusNotFound {
+		t.Errorf("getting a renamed safe by its old name returned status %d, want 404", status)
+	}
+
+	var page accountsPage
+	c.do(http.MethodGet, "/api/Accounts?filter=safeName%20eq%20Renamed", nil, &page)
+	if page.Count != 1 {
+		t.Errorf("found %d accounts in the renamed safe, want 1", page.Count)
+	}
+
+	if status := c.do(http.MethodDelete, "/api/Safes/Renamed", nil, nil); status != http.StatusConflict {
+		t.Errorf("deleting a safe with accounts returned status %d, want 409", status)
+	}
+	c.do(http.MethodDelete, "/api/Accounts/"+page.Value[0]["id"].(string), nil, nil)
+	if status := c.do(http.MethodDelete, "/api/Safes/Renamed", nil, nil); status != http.StatusNoContent {
+		t.Errorf("deleting a safe failed with status %d", status)
+	}
+}
+
+func TestSafeMembers(t *testing.T) {
+	c := newTestSession(t)
+
+	c.do(http.MethodPost, "/api/Safes", map[string]interface{}{"safeName": "Linux"}, nil)
+
+	var members safeMembersPage
+	c.do(http.MethodGet, "/api/Safes/Linux/Members", nil, &members)
+	if members.Count != 1 || members.Value[0].MemberName != "terraform" {
+		t.Errorf("unexpected members %+v", members)
+	}
+	c.do(http.MethodGet, "/api/Safes/Linux/Members?filter=includePredefinedUsers%20eq%20true", nil, &members)
+	if members.Count != 2 || !members.Value[0].IsPredefinedUser {
+		t.Errorf("unexpected members including predefined users %+v", members)
+	}
+
+	add := map[string]interface{}{
+		"memberName":  "Auditors",
+		"memberType":  "Group",
+		"permissions": map[string]bool{"listAccounts": true},
+	}
+	if status := c.do(http.MethodPost, "/api/Safes/Linux/Members", add, nil); status != http.StatusCreated {
+		t.Fatalf("adding a member failed with status %d", status)
+	}
+	if status := c.do(http.MethodPost, "/api/Safes/Linux/Members", add, nil); status != http.StatusConflict {
+		t.Errorf("adding a duplicate member returned status %d, want 409", status)
+	}
+
+	var member safeMember
+	c.do(http.MethodGet, "/api/Safes/Linux/members/auditors", nil, &member)
+	if member.MemberName != "Auditors" || member.MemberType != "Group" || !member.Permissions["listAccounts"] || member.Permissions["useAccounts"] {
+		t.Errorf("unexpected member %+v", member)
+	}
+
+	c.do(http.MethodPut, "/api/Safes/Linux/members/Auditors", map[string]interface{}{"Permissions": map[string]bool{"useAccounts": true}, "membershipExpirationDate": 4102444800}, &member)
+	if member.Permissions["listAccounts"] || !member.Permissions["useAccounts"] || member.MembershipExpirationDate == nil {
+		t.Errorf("unexpected updated member %+v", member)
+	}
+
+	member = safeMember{}
+	c.do(http.MethodPut, "/api/Safes/Linux/members/Auditors", map[string]interface{}{"Permissions": map[string]bool{"useAccounts": true}}, &member)
+	if member.MembershipExpirationDate == nil || *member.MembershipExpirationDate != 4102444800 {
+		t.Errorf("an update without membershipExpirationDate changed it to %v", member.MembershipExpirationDate)
+	}
+
+	member = safeMember{}
+	c.do(http.MethodPut, "/api/Safes/Linux/members/Auditors", map[string]interface{}{"Permissions": map[string]bool{"useAccounts": true}, "membershipExpirationDate": nil}, &member)
+	if member.MembershipExpirationDate != nil {
+		t.Errorf("an update with a null membershipExpirationDate left it at %d", *member.MembershipExpirationDate)
+	}
+
+	if status := c.do(http.MethodDelete, "/api/Safes/Linux/members/Master", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("removing a predefined member returned status %d, want 400", status)
+	}
+	if status := c.do(http.MethodDelete, "/api/Safes/Linux/members/Auditors", nil, nil); status != http.StatusNoContent {
+		t.Errorf("removing a member failed with status %d", status)
+	}
+	if status := c.do(http.MethodGet, "/api/Safes/Linux/members/Auditors", nil, nil); status != http.StatusNotFound {
+		t.Errorf("getting a removed member returned status %d, want 404", status)
+	}
+}
@@ -0,0 +1,747 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/umich-vci/gopas"
+)
+
+// accountPatchOperation is a JSON-patch operation on an account. It is used
+// instead of gopas.OperationAccountModel because that model only allows object
+// values, so single properties cannot be added, replaced or removed.
+type accountPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// accountPatchPath returns the JSON pointer to a field of an account, escaping
+// each segment as required by RFC 6901.
+func accountPatchPath(segments ...string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteString("/")
+		b.WriteString(escaper.Replace(s))
+	}
+	return b.String()
+}
+
+// accountPropertiesPatch returns the operations needed to change the platform
+// account properties of an account from old to new.
+func accountPropertiesPatch(old, new map[string]interface{}) []accountPatchOperation {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	operations := []accountPatchOperation{}
+	for _, k := range keys {
+		o, inOld := old[k]
+		n, inNew := new[k]
+		path := accountPatchPath("platformAccountProperties", k)
+
+		switch {
+		case !inOld:
+			operations = append(operations, accountPatchOperation{Op: "add", Path: path, Value: n})
+		case !inNew:
+			operations = append(operations, accountPatchOperation{Op: "remove", Path: path})
+		case o != n:
+			operations = append(operations, accountPatchOperation{Op: "replace", Path: path, Value: n})
+		}
+	}
+
+	return operations
+}
+
+// accountPropertiesChange returns the platform account properties held in the
+// attrs of a resource before and after a change, keyed by property name. attrs
+// maps attribute names to property names. Empty attributes are left out, so
+// clearing one removes its property.
+func accountPropertiesChange(d *schema.ResourceData, attrs map[string]string) (map[string]interface{}, map[string]interface{}) {
+	old := make(map[string]interface{})
+	new := make(map[string]interface{})
+	for attr, property := range attrs {
+		o, n := d.GetChange(attr)
+		if v := o.(string); v != "" {
+			old[property] = v
+		}
+		if v := n.(string); v != "" {
+			new[property] = v
+		}
+	}
+	return old, new
+}
+
+// updateAccount applies JSON-patch operations to an account.
+func updateAccount(ctx context.Context, client gopas.APIClient, id string, operations []accountPatchOperation) (*http.Response, error) {
+	if len(operations) == 0 {
+		return nil, nil
+	}
+
+	path := fmt.Sprintf("/api/Accounts/%s", url.PathEscape(id))
+	return apiRequest(ctx, client, http.MethodPatch, path, nil, operations, nil)
+}
+
+// setAccountSecret replaces the secret of an account in the Vault. The
+// secret cannot be changed with a JSON-patch update.
+func setAccountSecret(ctx context.Context, client gopas.APIClient, id, secret string) (*http.Response, error) {
+	change := *gopas.NewChangeInVaultProperties(secret)
+
+	_, resp, err := client.AccountsApi.AccountsChangeCredentialsInTheVault(ctx, id).ChangeProperties(change).Execute()
+	return resp, err
+}
+
+// accountSecretManagementSchema is the secret_management block shared by the
+// account resources.
+func accountSecretManagementSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "How the CPM manages the secret of the account. If not specified, the settings of the platform are used. Removing the block leaves the current settings of the account unchanged.",
+		Type:        schema.TypeList,
+		Optional:    true,
+		Computed:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"automatic_management_enabled": {
+					Description: "Whether the CPM manages the secret of the account automatically.",
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     true,
+				},
+				"manual_management_reason": {
+					Description: "Why the secret of the account is managed manually. This is only used when `automatic_management_enabled` is `false`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+				"status": {
+					Description: "The management status of the account.",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				"last_modified_time": {
+					Description: "When the secret was last modified, in Unix time.",
+					Type:        schema.TypeInt,
+					Computed:    true,
+				},
+				"last_reconciled_time": {
+					Description: "When the secret was last reconciled, in Unix time.",
+					Type:        schema.TypeInt,
+					Computed:    true,
+				},
+				"last_verified_time": {
+					Description: "When the secret was last verified, in Unix time.",
+					Type:        schema.TypeInt,
+					Computed:    true,
+				},
+			},
+		},
+	}
+}
+
+// expandAccountSecretManagement returns the secret management settings of an
+// account to send on create, or nil if the secret_management block is not set.
+func expandAccountSecretManagement(d *schema.ResourceData) *gopas.AutomaticSecretManagement {
+	v, ok := d.GetOk("secret_management")
+	if !ok || len(v.([]interface{})) == 0 || v.([]interface{})[0] == nil {
+		return nil
+	}
+
+	m := v.([]interface{})[0].(map[string]interface{})
+	enabled := m["automatic_management_enabled"].(bool)
+
+	sm := gopas.NewAutomaticSecretManagement()
+	sm.AutomaticManagementEnabled = &enabled
+	if reason := m["manual_management_reason"].(string); !enabled && reason != "" {
+		sm.ManualManagementReason = &reason
+	}
+
+	return sm
+}
+
+// accountSecretManagementPatch returns the JSON-patch operations that replace
+// the secret management settings of an account with the secret_management
+// block, if it changed. The block is computed, so removing it leaves the
+// settings of the account unchanged and no operation is returned.
+func accountSecretManagementPatch(d *schema.ResourceData) []accountPatchOperation {
+	sm := expandAccountSecretManagement(d)
+	if sm == nil || !d.HasChange("secret_management") {
+		return nil
+	}
+
+	value := map[string]interface{}{"automaticManagementEnabled": sm.GetAutomaticManagementEnabled()}
+	if sm.ManualManagementReason != nil {
+		value["manualManagementReason"] = *sm.ManualManagementReason
+	}
+
+	return []accountPatchOperation{{Op: "replace", Path: accountPatchPath("secretManagement"), Value: value}}
+}
+
+func flattenAccountSecretManagement(sm *gopas.AutomaticSecretManagement) []interface{} {
+	if sm == nil {
+		return nil
+	}
+
+	return []interface{}{map[string]interface{}{
+		"automatic_management_enabled": sm.GetAutomaticManagementEnabled(),
+		"manual_management_reason":     sm.GetManualManagementReason(),
+		"status":                       sm.GetStatus(),
+		"last_modified_time":           int(sm.GetLastModifiedTime()),
+		"last_reconciled_time":         int(sm.GetLastReconciledTime()),
+		"last_verified_time":           int(sm.GetLastVerifiedTime()),
+	}}
+}
+
+// accountRemoteMachinesAccessSchema is the remote_machines_access block of
+// the account resources.
+func accountRemoteMachinesAccessSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "The machines the account can be used to log on to. Removing the block leaves the current remote machines access of the account unchanged; to lift the restriction, set `access_restricted_to_remote_machines` to `false`.",
+		Type:        schema.TypeList,
+		Optional:    true,
+		Computed:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"remote_machines": {
+					Description:      "A semicolon-separated list of the machines the account can log on to. The order of the machines is ignored.",
+					Type:             schema.TypeString,
+					Optional:         true,
+					DiffSuppressFunc: suppressRemoteMachinesDiff,
+				},
+				"access_restricted_to_remote_machines": {
+					Description: "Whether logon is restricted to the machines in `remote_machines`.",
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+				},
+			},
+		},
+	}
+}
+
+// normalizeRemoteMachines returns a semicolon-separated machine list sorted,
+// with surrounding whitespace and empty entries removed.
+func normalizeRemoteMachines(machines string) string {
+	list := []string{}
+	for _, m := range strings.Split(machines, ";") {
+		if m = strings.TrimSpace(m); m != "" {
+			list = append(list, m)
+		}
+	}
+	sort.Strings(list)
+	return strings.Join(list, ";")
+}
+
+// suppressRemoteMachinesDiff suppresses diffs between machine lists that only
+// differ in order.
+func suppressRemoteMachinesDiff(k, old, new string, d *schema.ResourceData) bool {
+	return normalizeRemoteMachines(old) == normalizeRemoteMachines(new)
+}
+
+// expandAccountRemoteMachinesAccess returns the remote machines access of an
+// account to send on create, or nil if the remote_machines_access block is not
+// set.
+func expandAccountRemoteMachinesAccess(d *schema.ResourceData) *gopas.RemoteMachinesAccess {
+	v, ok := d.GetOk("remote_machines_access")
+	if !ok || len(v.([]interface{})) == 0 || v.([]interface{})[0] == nil {
+		return nil
+	}
+
+	m := v.([]interface{})[0].(map[string]interface{})
+	machines := m["remote_machines"].(string)
+	restricted := m["access_restricted_to_remote_machines"].(bool)
+
+	rma := gopas.NewRemoteMachinesAccess()
+	rma.RemoteMachines = &machines
+	rma.AccessRestrictedToRemoteMachines = &restricted
+
+	return rma
+}
+
+// accountRemoteMachinesAccessPatch returns the JSON-patch operations that set
+// the remote machines access of an account to the remote_machines_access
+// block, if it changed. The block is computed, so removing it leaves the
+// remote machines access of the account unchanged and no operation is
+// returned.
+func accountRemoteMachinesAccessPatch(d *schema.ResourceData) []accountPatchOperation {
+	rma := expandAccountRemoteMachinesAccess(d)
+	if rma == nil || !d.HasChange("remote_machines_access") {
+		return nil
+	}
+
+	value := map[string]interface{}{
+		"remoteMachines":                   rma.GetRemoteMachines(),
+		"accessRestrictedToRemoteMachines": rma.GetAccessRestrictedToRemoteMachines(),
+	}
+
+	// add replaces the remote machines access if the account already has it.
+	return []accountPatchOperation{{Op: "add", Path: accountPatchPath("remoteMachinesAccess"), Value: value}}
+}
+
+func flattenAccountRemoteMachinesAccess(rma *gopas.RemoteMachinesAccess) []interface{} {
+	if rma == nil {
+		return nil
+	}
+
+	return []interface{}{map[string]interface{}{
+		"remote_machines":                      rma.GetRemoteMachines(),
+		"access_restricted_to_remote_machines": rma.GetAccessRestrictedToRemoteMachines(),
+	}}
+}
+
+// linkAccount links the account in a linked account block, such as
+// change_account, to the account with an ID at extraPasswordIndex index.
+func linkAccount(ctx context.Context, client gopas.APIClient, id string, block map[string]interface{}, index int32) (*http.Response, error) {
+	linkAccount := *gopas.NewLinkAccountData(block["name"].(string), block["safe_name"].(string), block["folder"].(string), index)
+
+	return client.AccountsApi.AccountsLinkAccount(ctx, id).LinkAccount(linkAccount).Execute()
+}
+
+// updateLinkedAccount replaces the account linked at extraPasswordIndex index
+// with the one in the attr block, if it changed.
+func updateLinkedAccount(ctx context.Context, client gopas.APIClient, d *schema.ResourceData, attr string, index int32) diag.Diagnostics {
+	if !d.HasChange(attr) {
+		return nil
+	}
+
+	id := d.Id()
+	o, n := d.GetChange(attr)
+
+	if len(o.([]interface{})) > 0 {
+		resp, err := client.AccountsApi.AccountsClearAccount(ctx, id, index).Execute()
+		if err != nil {
+			return apiErrorDiags(resp, err)
+		}
+	}
+
+	if len(n.([]interface{})) > 0 {
+		resp, err := linkAccount(ctx, client, id, n.([]interface{})[0].(map[string]interface{}), index)
+		if err != nil {
+			return apiErrorDiags(resp, err)
+		}
+	}
+
+	return nil
+}
+
+// readLinkedAccounts returns the accounts linked to an account as linked
+// account blocks, keyed by their extraPasswordIndex. Linked accounts are only
+// returned by the legacy Accounts API.
+func readLinkedAccounts(ctx context.Context, client gopas.APIClient, account gopas.AccountModel) (map[int]map[string]interface{}, diag.Diagnostics) {
+	id := account.GetId()
+
+	accountv1, resp, err := client.AccountsApi.AccountsGetAccountLegacy(ctx).Keywords(account.GetUserName()).Safe(account.SafeName).Execute()
+	if err != nil {
+		var diags diag.Diagnostics
+		diags = append(diags, diag.Errorf("Error finding account name %s with legacy API", account.GetName())...)
+		diags = append(diags, apiErrorDiags(resp, err)...)
+		return nil, diags
+	}
+
+	var internalProperties []gopas.KeyValuePair
+	found := false
+	for _, a := range accountv1.GetAccounts() {
+		if a.GetAccountID() == id {
+			internalProperties = a.GetInternalProperties()
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, diag.Errorf("account %s was not found with the legacy Accounts API, so its linked accounts cannot be read", id)
+	}
+
+	fields := map[string]string{
+		"Name":   "name",
+		"Folder": "folder",
+		"Safe":   "safe_name",
+	}
+
+	links := map[int]map[string]interface{}{}
+	for _, kv := range internalProperties {
+		var index int
+		var field string
+		if _, err := fmt.Sscanf(kv.GetKey(), "ExtraPass%1d%s", &index, &field); err != nil || fields[field] == "" {
+			continue
+		}
+		if links[index] == nil {
+			links[index] = map[string]interface{}{}
+		}
+		links[index][fields[field]] = kv.GetValue()
+	}
+
+	return links, nil
+}
+
+// flattenLinkedAccount returns the linked account block of the account linked
+// at extraPasswordIndex index, or nil if none is linked there.
+func flattenLinkedAccount(links map[int]map[string]interface{}, index int) []interface{} {
+	if len(links[index]) == 0 {
+		return nil
+	}
+
+	return []interface{}{links[index]}
+}
+
+// accountsPageSize is the number of accounts requested per page when listing
+// accounts. It is the maximum the PVWA allows.
+const accountsPageSize = 1000
+
+// listAccounts returns every account matching the search, paging through the
+// results with offset and limit.
+func listAccounts(ctx context.Context, client gopas.APIClient, search, searchType, filter string, sort []string) ([]gopas.AccountModel, *http.Response, error) {
+	accounts := []gopas.AccountModel{}
+
+	for {
+		req := client.AccountsApi.AccountsGetAccounts(ctx).Offset(int32(len(accounts))).Limit(accountsPageSize)
+		if search != "" {
+			req = req.Search(search)
+		}
+		if searchType != "" {
+			req = req.SearchType(searchType)
+		}
+		if filter != "" {
+			req = req.Filter(filter)
+		}
+		if len(sort) > 0 {
+			req = req.Sort(sort)
+		}
+
+		page, resp, err := req.Execute()
+		if err != nil {
+			return nil, resp, err
+		}
+
+		value := page.GetValue()
+		accounts = append(accounts, value...)
+
+		if len(value) == 0 || len(accounts) >= int(page.GetCount()) {
+			return accounts, resp, nil
+		}
+	}
+}
+
+// resourceAccountImport imports an account by its ID or by its safe name and
+// account name separated by a slash. The latter is looked up with the
+// Accounts search, which must find exactly one account with that name.
+func resourceAccountImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*apiClient).Client
+
+	if safeName, name, ok := strings.Cut(d.Id(), "/"); ok {
+		if safeName == "" || name == "" {
+			return nil, fmt.Errorf("unexpected format of ID (%s), expected account_id or safe_name/account_name", d.Id())
+		}
+
+		accounts, resp, err := listAccounts(ctx, client, name, "", accountsFilter(safeName, 0), nil)
+		if err != nil {
+			return nil, fmt.Errorf("searching for account %q in safe %q: %w", name, safeName, newAPIError(resp, err))
+		}
+
+		accounts = matchAccounts(accounts, name, "", "")
+
+		if len(accounts) == 0 {
+			return nil, fmt.Errorf("no account named %q was found in safe %q", name, safeName)
+		}
+		if len(accounts) > 1 {
+			return nil, fmt.Errorf("%d accounts named %q were found in safe %q; import one by its account ID instead", len(accounts), name, safeName)
+		}
+
+		d.SetId(accounts[0].GetId())
+	}
+
+	// Terraform has not set the secret of an imported account, so it is
+	// imported with the default secret_lifecycle and no managed version.
+	d.Set("secret_lifecycle", secretLifecycleInitialOnly)
+	d.Set("managed_secret_version_id", 0)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// accountSecretRetrieveRequest is the body of the Password Retrieve API. It is
+// used instead of gopas.AccountContentPrerequsites because that model names
+// the ticketing system field incorrectly.
+type accountSecretRetrieveRequest struct {
+	Reason              string `json:"reason,omitempty"`
+	TicketingSystemName string `json:"TicketingSystemName,omitempty"`
+	TicketID            string `json:"TicketId,omitempty"`
+	Version             int    `json:"Version,omitempty"`
+	ActionType          string `json:"ActionType,omitempty"`
+}
+
+// retrieveAccountSecret returns the secret of an account. The PVWA returns
+// the secret as a JSON string, which gopas cannot decode.
+func retrieveAccountSecret(ctx context.Context, client gopas.APIClient, id string, body accountSecretRetrieveRequest) (string, *http.Response, error) {
+	var secret string
+
+	path := fmt.Sprintf("/api/Accounts/%s/Password/Retrieve", url.PathEscape(id))
+	resp, err := apiRequest(ctx, client, http.MethodPost, path, nil, body, &secret)
+	return secret, resp, err
+}
+
+// isRequestRequiredErr reports whether a failed retrieval was rejected
+// because the account is protected by dual control and no approved request
+// covers it.
+func isRequestRequiredErr(resp *http.Response) bool {
+	if resp == nil || resp.StatusCode < 400 {
+		return false
+	}
+
+	msg := strings.ToLower(responseError(resp).ErrorMessage)
+	if !strings.Contains(msg, "request") {
+		return false
+	}
+	for _, s := range []string{"confirm", "approv", "dual control", "required"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// retrieveAccountSecretErr returns the diagnostics for a failed retrieval,
+// explaining dual control rejections instead of returning the raw body.
+func retrieveAccountSecretErr(id string, resp *http.Response, err error) diag.Diagnostics {
+	if !isRequestRequiredErr(resp) {
+		return apiErrorDiags(resp, err)
+	}
+
+	e := responseError(resp)
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  "Dual control request required",
+		Detail: fmt.Sprintf("The secret of account %s is protected by dual control and no approved request covers this retrieval (%s: %s). "+
+			"Create a request for the account in the PVWA and wait for it to be approved, or provide a reason and ticket that satisfy the platform's policy, then try again.",
+			id, e.ErrorCode, e.ErrorMessage),
+	}}
+}
+
+// writeOnlyString returns the configured value of a write-only string
+// attribute. Write-only values are never stored in the state, so they can
+// only be read from the raw configuration during create and update.
+func writeOnlyString(d *schema.ResourceData, attr string) (string, diag.Diagnostics) {
+	v, diags := d.GetRawConfigAt(cty.GetAttrPath(attr))
+	if diags.HasError() {
+		return "", diags
+	}
+
+	if !v.IsKnown() || v.IsNull() || !v.Type().Equals(cty.String) {
+		return "", nil
+	}
+
+	return v.AsString(), nil
+}
+
+const (
+	secretLifecycleInitialOnly = "initial_only"
+	secretLifecycleEnforce     = "enforce"
+	secretLifecycleIgnore      = "ignore"
+)
+
+// accountSecretLifecycleSchema returns the schema of the secret_lifecycle
+// attribute, which decides how the write-only attr is kept in the Vault.
+func accountSecretLifecycleSchema(attr, versionAttr string) *schema.Schema {
+	return &schema.Schema{
+		Description: fmt.Sprintf("How Terraform manages `%[1]s` once the CPM may rotate it. With `initial_only`, `%[1]s` is sent on create and when `%[2]s` changes. "+
+			"With `enforce`, the Secret Versions API is also checked on every read, and `%[1]s` is sent again when the secret was changed outside of Terraform. "+
+			"With `ignore`, `%[1]s` is never sent.", attr, versionAttr),
+		Type:         schema.TypeString,
+		Optional:     true,
+		Default:      secretLifecycleInitialOnly,
+		ValidateFunc: validation.StringInSlice([]string{secretLifecycleInitialOnly, secretLifecycleEnforce, secretLifecycleIgnore}, false),
+	}
+}
+
+// managedSecretVersionIDSchema and vaultSecretVersionIDSchema are the
+// computed attributes used to detect secret changes made outside of Terraform
+// when secret_lifecycle is enforce.
+func managedSecretVersionIDSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "The version of the secret in the Vault that Terraform last set. This is only tracked when `secret_lifecycle` is `enforce`.",
+		Type:        schema.TypeInt,
+		Computed:    true,
+	}
+}
+
+func vaultSecretVersionIDSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "The current version of the secret in the Vault. This is only read when `secret_lifecycle` is `enforce`.",
+		Type:        schema.TypeInt,
+		Computed:    true,
+	}
+}
+
+// createSecret returns the write-only secret to send when an account is
+// created, or an empty string if it must not be sent.
+func createSecret(d *schema.ResourceData, attr string) (string, diag.Diagnostics) {
+	if d.Get("secret_lifecycle").(string) == secretLifecycleIgnore {
+		return "", nil
+	}
+
+	return writeOnlyString(d, attr)
+}
+
+// secretDrifted reports whether the secret of an account was changed outside
+// of Terraform since Terraform last set it.
+func secretDrifted(managed, vault int) bool {
+	return managed > 0 && vault > 0 && managed != vault
+}
+
+// customizeDiffSecretDrift plans an update of an account whose secret was
+// changed outside of Terraform, such as by a CPM rotation, when
+// secret_lifecycle is enforce and the write-only attr is configured.
+func customizeDiffSecretDrift(attr string) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		if d.Id() == "" || d.Get("secret_lifecycle").(string) != secretLifecycleEnforce {
+			return nil
+		}
+
+		config := d.GetRawConfig()
+		if config.IsNull() || !config.IsKnown() || config.GetAttr(attr).IsNull() {
+			return nil
+		}
+
+		if secretDrifted(d.Get("managed_secret_version_id").(int), d.Get("vault_secret_version_id").(int)) {
+			return d.SetNewComputed("vault_secret_version_id")
+		}
+
+		return nil
+	}
+}
+
+// updateSecret sends the configured write-only secret of an account to the
+// Vault when the attribute tracking its version changes or, when
+// secret_lifecycle is enforce, when the secret was changed outside of
+// Terraform.
+func updateSecret(ctx context.Context, client gopas.APIClient, d *schema.ResourceData, attr, versionAttr string) diag.Diagnostics {
+	lifecycle := d.Get("secret_lifecycle").(string)
+	if lifecycle == secretLifecycleIgnore {
+		return nil
+	}
+
+	vault, _ := d.GetChange("vault_secret_version_id")
+	drifted := lifecycle == secretLifecycleEnforce && secretDrifted(d.Get("managed_secret_version_id").(int), vault.(int))
+
+	if d.HasChange(versionAttr) || drifted {
+		secret, diags := writeOnlyString(d, attr)
+		if diags.HasError() {
+			return diags
+		}
+		if secret == "" {
+			return diag.Errorf("%s must be set when %s changes", attr, versionAttr)
+		}
+
+		resp, err := setAccountSecret(ctx, client, d.Id(), secret)
+		if err != nil {
+			return apiErrorDiags(resp, err)
+		}
+
+		return recordSecretVersion(ctx, client, d)
+	}
+
+	if d.HasChange("secret_lifecycle") {
+		return recordSecretVersion(ctx, client, d)
+	}
+
+	return nil
+}
+
+// recordSecretVersion records the current secret version of an account as
+// the one Terraform manages, when secret_lifecycle is enforce.
+func recordSecretVersion(ctx context.Context, client gopas.APIClient, d *schema.ResourceData) diag.Diagnostics {
+	if d.Get("secret_lifecycle").(string) != secretLifecycleEnforce {
+		d.Set("managed_secret_version_id", 0)
+		return nil
+	}
+
+	version, resp, err := latestSecretVersion(ctx, client, d.Id())
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	d.Set("managed_secret_version_id", version)
+	return nil
+}
+
+// readSecretVersion reads the current secret version of an account, when
+// secret_lifecycle is enforce.
+func readSecretVersion(ctx context.Context, client gopas.APIClient, d *schema.ResourceData) diag.Diagnostics {
+	if d.Get("secret_lifecycle").(string) != secretLifecycleEnforce {
+		d.Set("vault_secret_version_id", 0)
+		return nil
+	}
+
+	version, resp, err := latestSecretVersion(ctx, client, d.Id())
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	d.Set("vault_secret_version_id", version)
+	return nil
+}
+
+// secretVersion is a version of an account secret returned by the Secret
+// Versions API.
+type secretVersion struct {
+	VersionID   int  `json:"versionID"`
+	IsTemporary bool `json:"isTemporary"`
+}
+
+// secretVersions decodes the Secret Versions API response, which the PVWA
+// wraps in a Versions object, although gopas expects a bare list.
+type secretVersions []secretVersion
+
+func (v *secretVersions) UnmarshalJSON(b []byte) error {
+	var list []secretVersion
+	if err := json.Unmarshal(b, &list); err == nil {
+		*v = list
+		return nil
+	}
+
+	var wrapped struct {
+		Versions []secretVersion `json:"Versions"`
+	}
+	if err := json.Unmarshal(b, &wrapped); err != nil {
+		return err
+	}
+	*v = wrapped.Versions
+	return nil
+}
+
+// latestSecretVersion returns the ID of the newest permanent version of the
+// secret of an account.
+func latestSecretVersion(ctx context.Context, client gopas.APIClient, id string) (int, *http.Response, error) {
+	var versions secretVersions
+
+	path := fmt.Sprintf("/api/Accounts/%s/Secret/Versions", url.PathEscape(id))
+	resp, err := apiRequest(ctx, client, http.MethodGet, path, nil, nil, &versions)
+	if err != nil {
+		return 0, resp, err
+	}
+
+	latest := 0
+	for _, v := range versions {
+		if !v.IsTemporary && v.VersionID > latest {
+			latest = v.VersionID
+		}
+	}
+
+	return latest, resp, nil
+}
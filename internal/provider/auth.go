@@ -0,0 +1,382 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/umich-vci/gopas"
+)
+
+const (
+	authMethodLogon  = "logon"
+	authMethodOAuth2 = "oauth2"
+	authMethodToken  = "token"
+
+	// authTypePKI is the logon authentication type that authenticates with
+	// the client certificate instead of a username and password.
+	authTypePKI = "pki"
+
+	// authTypeSAML and authTypeWindows are the logon authentication types
+	// that exchange an assertion obtained outside of the provider.
+	authTypeSAML    = "saml"
+	authTypeWindows = "windows"
+)
+
+// authenticator obtains the value of the Authorization header sent with
+// every PVWA request. Each authentication method implements it, so that new
+// methods only need to be added to newAuthenticator.
+type authenticator interface {
+	authenticate(ctx context.Context, client *gopas.APIClient) (string, error)
+}
+
+// newAuthenticator returns the authenticator chosen by the auth block of the
+// provider configuration. Without an auth block, the classic logon is used.
+func newAuthenticator(d *schema.ResourceData) (authenticator, error) {
+	method := authMethodLogon
+	auth := map[string]interface{}{}
+	if v, ok := d.GetOk("auth"); ok && len(v.([]interface{})) > 0 && v.([]interface{})[0] != nil {
+		auth = v.([]interface{})[0].(map[string]interface{})
+		method = auth["method"].(string)
+	}
+
+	switch method {
+	case authMethodLogon:
+		a := &logonAuthenticator{
+			username: d.Get("username").(string),
+			password: d.Get("password").(string),
+			authType: d.Get("auth_type").(string),
+			otp:      d.Get("radius_otp").(string),
+		}
+		for _, arg := range d.Get("otp_command").([]interface{}) {
+			a.otpCommand = append(a.otpCommand, arg.(string))
+		}
+		source, err := newCredentialSource(d)
+		if err != nil {
+			return nil, err
+		}
+		a.source = source
+		switch strings.ToLower(a.authType) {
+		case "":
+			return nil, fmt.Errorf("auth_type must be set to log on")
+		case authTypePKI:
+			if d.Get("client_cert_file").(string) == "" && d.Get("client_cert_pem").(string) == "" {
+				return nil, fmt.Errorf("a client certificate must be set to log on with the pki authentication type")
+			}
+			return a, nil
+		case authTypeSAML, authTypeWindows:
+			a := &assertionAuthenticator{
+				authType:  strings.ToLower(a.authType),
+				assertion: d.Get("assertion").(string),
+			}
+			for _, arg := range d.Get("assertion_command").([]interface{}) {
+				a.command = append(a.command, arg.(string))
+			}
+			if a.assertion == "" && len(a.command) == 0 {
+				return nil, fmt.Errorf("assertion or assertion_command must be set to log on with the %s authentication type", a.authType)
+			}
+			return a, nil
+		}
+		if a.source == nil && (a.username == "" || a.password == "") {
+			return nil, fmt.Errorf("username and password, or credential_source, must be set to log on with the %s authentication type", a.authType)
+		}
+		return a, nil
+	case authMethodOAuth2:
+		a := &oauth2Authenticator{
+			tenantURL:    auth["identity_tenant_url"].(string),
+			clientID:     auth["client_id"].(string),
+			clientSecret: auth["client_secret"].(string),
+		}
+		if a.tenantURL == "" || a.clientID == "" || a.clientSecret == "" {
+			return nil, fmt.Errorf("identity_tenant_url, client_id and client_secret must be set to use the oauth2 method")
+		}
+		return a, nil
+	case authMethodToken:
+		a := &tokenAuthenticator{token: auth["token"].(string)}
+		if a.token == "" {
+			return nil, fmt.Errorf("token must be set to use the token method")
+		}
+		return a, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication method %q", method)
+	}
+}
+
+// logonAuthenticator logs on to the PVWA with a username and password, using
+// the CyberArk, LDAP or RADIUS authentication type, or with the client
+// certificate using the PKI authentication type. When a RADIUS server answers
+// with a challenge, the one-time password is sent as the response. With a
+// credential source, the credentials are fetched again at every logon, so
+// that a password changed by the CPM is picked up when the session expires.
+type logonAuthenticator struct {
+	username   string
+	password   string
+	authType   string
+	otp        string
+	otpCommand []string
+	source     credentialSource
+}
+
+// radiusChallengeCode is the PVWA error code of a logon that the RADIUS
+// server answered with a challenge, whose response must be sent as the
+// password of a second logon in the same HTTP session.
+const radiusChallengeCode = "ITATS542I"
+
+func (a *logonAuthenticator) authenticate(ctx context.Context, client *gopas.APIClient) (string, error) {
+	concurrent := true
+
+	username, password := a.username, a.password
+	if a.source != nil {
+		sourceUsername, sourcePassword, err := a.source.credentials(ctx, client)
+		if err != nil {
+			return "", err
+		}
+		if username == "" {
+			username = sourceUsername
+		}
+		if username == "" {
+			return "", fmt.Errorf("username must be set when the credential source does not provide it")
+		}
+		password = sourcePassword
+	}
+
+	data := *gopas.NewLogonData()
+	data.ConcurrentSession = &concurrent
+	if !strings.EqualFold(a.authType, authTypePKI) {
+		data.UserName = &username
+		data.Password = &password
+	}
+
+	// The PVWA keeps the state of a RADIUS challenge in a cookie, so each
+	// logon gets its own cookie jar.
+	client = withCookieJar(client)
+
+	resp, err := client.AuthApi.AuthLogon(ctx, a.authType).Data(data).Execute()
+	if err != nil {
+		e := responseError(resp)
+		if e.ErrorCode != radiusChallengeCode {
+			return "", newAPIError(resp, err)
+		}
+
+		otp, err := a.challengeResponse(ctx, e.ErrorMessage)
+		if err != nil {
+			return "", err
+		}
+		data.Password = &otp
+
+		resp, err = client.AuthApi.AuthLogon(ctx, a.authType).Data(data).Execute()
+		if err != nil {
+			return "", fmt.Errorf("responding to the RADIUS challenge: %w", newAPIError(resp, err))
+		}
+	}
+
+	return logonToken(resp)
+}
+
+// challengeResponse returns the one-time password that answers a RADIUS
+// challenge. otp_command is run with the challenge in PAS_RADIUS_CHALLENGE.
+func (a *logonAuthenticator) challengeResponse(ctx context.Context, challenge string) (string, error) {
+	if a.otp != "" {
+		return a.otp, nil
+	}
+	if len(a.otpCommand) == 0 {
+		return "", fmt.Errorf("the RADIUS server requested a one-time password (%s); set radius_otp or otp_command to provide it", challenge)
+	}
+
+	otp, err := commandOutput(ctx, a.otpCommand, "PAS_RADIUS_CHALLENGE="+challenge)
+	if err != nil {
+		return "", fmt.Errorf("running otp_command: %w", err)
+	}
+
+	return otp, nil
+}
+
+// withCookieJar returns a copy of client whose HTTP client keeps cookies in a
+// new cookie jar.
+func withCookieJar(client *gopas.APIClient) *gopas.APIClient {
+	cfg := *client.GetConfig()
+
+	httpClient := *cfg.HTTPClient
+	httpClient.Jar, _ = cookiejar.New(nil)
+	cfg.HTTPClient = &httpClient
+
+	return gopas.NewAPIClient(&cfg)
+}
+
+func (a *logonAuthenticator) logoff(ctx context.Context, client *gopas.APIClient) error {
+	return logoffSession(ctx, client)
+}
+
+// logonToken returns the session token from a PVWA logon response. The token
+// is returned as a JSON string and is sent without an authorization scheme.
+func logonToken(resp *http.Response) (string, error) {
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var token string
+	if err := json.Unmarshal(b, &token); err != nil {
+		return "", fmt.Errorf("decoding logon token: %w", err)
+	}
+
+	return token, nil
+}
+
+// assertionAuthenticator logs on to the PVWA with an assertion obtained
+// outside of the provider: a SAML response for the SAML authentication type,
+// or a Kerberos SPNEGO token for the Windows authentication type. The
+// assertion is either given in the configuration or printed by a command,
+// which is run at every logon so that it can return a fresh assertion.
+type assertionAuthenticator struct {
+	authType  string
+	assertion string
+	command   []string
+}
+
+func (a *assertionAuthenticator) authenticate(ctx context.Context, client *gopas.APIClient) (string, error) {
+	assertion := a.assertion
+	if assertion == "" {
+		out, err := commandOutput(ctx, a.command)
+		if err != nil {
+			return "", fmt.Errorf("running assertion_command: %w", err)
+		}
+		assertion = out
+	}
+
+	u, err := apiURL(ctx, *client, "/api/Auth/"+a.authType+"/Logon")
+	if err != nil {
+		return "", err
+	}
+
+	var req *http.Request
+	if a.authType == authTypeSAML {
+		form := url.Values{
+			"concurrentSession": {"true"},
+			"apiUse":            {"true"},
+			"SAMLResponse":      {assertion},
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(`{"concurrentSession":true}`))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Negotiate "+assertion)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", client.GetConfig().UserAgent)
+
+	resp, err := client.GetConfig().HTTPClient.Do(req)
+	if err != nil {
+		return "", newAPIError(nil, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", newAPIError(resp, errors.New(resp.Status))
+	}
+
+	return logonToken(resp)
+}
+
+func (a *assertionAuthenticator) logoff(ctx context.Context, client *gopas.APIClient) error {
+	return logoffSession(ctx, client)
+}
+
+// commandOutput runs a command given as a program and its arguments, with env
+// added to the environment of the provider, and returns what it printed with
+// surrounding whitespace removed. What the command printed to stderr is
+// included in the error if it fails.
+func commandOutput(ctx context.Context, command []string, env ...string) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("no command given")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// oauth2Authenticator gets a bearer token for Privileged Cloud from the
+// Identity tenant with the OAuth2 client credentials grant.
+type oauth2Authenticator struct {
+	tenantURL    string
+	clientID     string
+	clientSecret string
+}
+
+func (a *oauth2Authenticator) authenticate(ctx context.Context, client *gopas.APIClient) (string, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.clientID},
+		"client_secret": {a.clientSecret},
+	}
+
+	u := strings.TrimSuffix(a.tenantURL, "/") + "/oauth2/platformtoken"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.GetConfig().HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil && resp.StatusCode < 300 {
+		return "", fmt.Errorf("decoding OAuth2 token: %w", err)
+	}
+
+	if resp.StatusCode >= 300 || token.AccessToken == "" {
+		return "", fmt.Errorf("requesting OAuth2 token from %s: %s: %s %s", a.tenantURL, resp.Status, token.Error, token.ErrorDescription)
+	}
+
+	return "Bearer " + token.AccessToken, nil
+}
+
+// tokenAuthenticator uses a token issued outside of the provider.
+type tokenAuthenticator struct {
+	token string
+}
+
+func (a *tokenAuthenticator) authenticate(ctx context.Context, client *gopas.APIClient) (string, error) {
+	return a.token, nil
+}
@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/umich-vci/gopas"
+)
+
+func TestNewAuthenticator(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		want    authenticator
+		wantErr bool
+	}{
+		{
+			name: "logon without block",
+			raw:  map[string]interface{}{"username": "u", "password": "p", "auth_type": "ldap"},
+			want: &logonAuthenticator{username: "u", password: "p", authType: "ldap"},
+		},
+		{
+			name:    "logon without password",
+			raw:     map[string]interface{}{"username": "u", "auth_type": "ldap"},
+			wantErr: true,
+		},
+		{
+			name: "radius with otp_command",
+			raw:  map[string]interface{}{"username": "u", "password": "p", "auth_type": "radius", "otp_command": []interface{}{"otp-helper"}},
+			want: &logonAuthenticator{username: "u", password: "p", authType: "radius", otpCommand: []string{"otp-helper"}},
+		},
+		{
+			name: "pki",
+			raw:  map[string]interface{}{"auth_type": "pki", "client_cert_file": "client.crt", "client_key_file": "client.key"},
+			want: &logonAuthenticator{authType: "pki"},
+		},
+		{
+			name:    "pki without certificate",
+			raw:     map[string]interface{}{"auth_type": "pki"},
+			wantErr: true,
+		},
+		{
+			name: "saml",
+			raw:  map[string]interface{}{"auth_type": "SAML", "assertion_command": []interface{}{"saml-helper", "--app", "pvwa"}},
+			want: &assertionAuthenticator{authType: "saml", command: []string{"saml-helper", "--app", "pvwa"}},
+		},
+		{
+			name:    "windows without assertion",
+			raw:     map[string]interface{}{"auth_type": "windows"},
+			wantErr: true,
+		},
+		{
+			name: "oauth2",
+			raw: map[string]interface{}{"auth": []interface{}{map[string]interface{}{
+				"method":              "oauth2",
+				"identity_tenant_url": "https://abc1234.id.cyberark.cloud",
+				"client_id":           "svc@cyberark.cloud.1234",
+				"client_secret":       "s3cret",
+			}}},
+			want: &oauth2Authenticator{tenantURL: "https://abc1234.id.cyberark.cloud", clientID: "svc@cyberark.cloud.1234", clientSecret: "s3cret"},
+		},
+		{
+			name:    "oauth2 without tenant",
+			raw:     map[string]interface{}{"auth": []interface{}{map[string]interface{}{"method": "oauth2", "client_id": "c", "client_secret": "s"}}},
+			wantErr: true,
+		},
+		{
+			name: "token",
+			raw:  map[string]interface{}{"auth": []interface{}{map[string]interface{}{"method": "token", "token": "Bearer abc"}}},
+			want: &tokenAuthenticator{token: "Bearer abc"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, env := range []string{"PAS_USERNAME", "PAS_PASSWORD", "PAS_AUTH_TYPE", "PAS_IDENTITY_TENANT_URL", "PAS_CLIENT_ID", "PAS_CLIENT_SECRET", "PAS_TOKEN", "PAS_CLIENT_CERT_FILE", "PAS_CLIENT_KEY_FILE", "PAS_ASSERTION", "PAS_RADIUS_OTP"} {
+				t.Setenv(env, "")
+			}
+
+			d := schema.TestResourceDataRaw(t, New("dev")().Schema, c.raw)
+			got, err := newAuthenticator(d)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", c.want) {
+				t.Errorf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func testAuthClient(t *testing.T, handler http.HandlerFunc) (*gopas.APIClient, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	config := gopas.NewConfiguration()
+	config.Host = u.Host
+	config.Scheme = u.Scheme
+
+	return gopas.NewAPIClient(config), server.URL
+}
+
+func TestLogonAuthenticator(t *testing.T) {
+	client, _ := testAuthClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/PasswordVault/api/Auth/ldap/Logon" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `"session-token"`)
+	})
+
+	a := &logonAuthenticator{username: "u", password: "p", authType: "ldap"}
+	token, err := a.authenticate(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "session-token" {
+		t.Errorf("got token %q", token)
+	}
+}
+
+func TestLogonAuthenticatorRADIUSChallenge(t *testing.T) {
+	client, _ := testAuthClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var data gopas.LogonData
+		json.NewDecoder(r.Body).Decode(&data)
+		w.Header().Set("Content-Type", "application/json")
+
+		_, err := r.Cookie("radius")
+		switch {
+		case err != nil && data.GetPassword() == "p":
+			http.SetCookie(w, &http.Cookie{Name: "radius", Value: "challenge"})
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"ErrorCode":"ITATS542I","ErrorMessage":"Enter the code from your token"}`)
+		case err == nil && data.GetPassword() == "123456":
+			fmt.Fprint(w, `"session-token"`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"ErrorCode":"ITATS127E","ErrorMessage":"Authentication failure"}`)
+		}
+	})
+
+	cases := []struct {
+		name       string
+		otp        string
+		otpCommand []string
+		wantErr    bool
+	}{
+		{name: "radius_otp", otp: "123456"},
+		{name: "otp_command", otpCommand: []string{"sh", "-c", `test "$PAS_RADIUS_CHALLENGE" = "Enter the code from your token" && echo 123456`}},
+		{name: "wrong otp", otp: "654321", wantErr: true},
+		{name: "no otp", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := &logonAuthenticator{username: "u", password: "p", authType: "radius", otp: c.otp, otpCommand: c.otpCommand}
+			token, err := a.authenticate(context.Background(), client)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got token %q", token)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "session-token" {
+				t.Errorf("got token %q", token)
+			}
+		})
+	}
+}
+
+func TestAssertionAuthenticator(t *testing.T) {
+	client, _ := testAuthClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var assertion string
+		switch r.URL.Path {
+		case "/PasswordVault/api/Auth/saml/Logon":
+			r.ParseForm()
+			assertion = r.PostForm.Get("SAMLResponse")
+		case "/PasswordVault/api/Auth/windows/Logon":
+			assertion = strings.TrimPrefix(r.Header.Get("Authorization"), "Negotiate ")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if assertion == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"ErrorCode":"PASWS013E","ErrorMessage":"Authentication failure."}`)
+			return
+		}
+		fmt.Fprintf(w, "%q", "token-for-"+assertion)
+	})
+
+	cases := []struct {
+		name      string
+		a         *assertionAuthenticator
+		wantToken string
+		wantErr   bool
+	}{
+		{name: "saml", a: &assertionAuthenticator{authType: "saml", assertion: "PHNhbWw+"}, wantToken: "token-for-PHNhbWw+"},
+		{name: "saml command", a: &assertionAuthenticator{authType: "saml", command: []string{"echo", "PHNhbWw+"}}, wantToken: "token-for-PHNhbWw+"},
+		{name: "windows", a: &assertionAuthenticator{authType: "windows", assertion: "YIIG"}, wantToken: "token-for-YIIG"},
+		{name: "failing command", a: &assertionAuthenticator{authType: "saml", command: []string{"false"}}, wantErr: true},
+		{name: "rejected", a: &assertionAuthenticator{authType: "saml", command: []string{"true"}}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			token, err := c.a.authenticate(context.Background(), client)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got token %q", token)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != c.wantToken {
+				t.Errorf("got token %q, want %q", token, c.wantToken)
+			}
+		})
+	}
+}
+
+func TestOAuth2Authenticator(t *testing.T) {
+	client, serverURL := testAuthClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/oauth2/platformtoken" || r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_secret") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"unauthorized_client","error_description":"invalid client"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"abc","token_type":"Bearer","expires_in":900}`)
+	})
+
+	a := &oauth2Authenticator{tenantURL: serverURL + "/", clientID: "svc", clientSecret: "s3cret"}
+	token, err := a.authenticate(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "Bearer abc" {
+		t.Errorf("got token %q", token)
+	}
+
+	a.clientSecret = "wrong"
+	if _, err := a.authenticate(context.Background(), client); err == nil {
+		t.Error("expected an error for invalid client credentials")
+	}
+}
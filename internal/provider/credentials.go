@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/umich-vci/gopas"
+)
+
+const (
+	credentialSourceCCP         = "ccp"
+	credentialSourcePasswordSDK = "password_sdk"
+	credentialSourceFile        = "file"
+	credentialSourceCommand     = "command"
+
+	// defaultPasswordSDKPath is where the Credential Provider installs the
+	// CLI Password SDK on Linux.
+	defaultPasswordSDKPath = "/opt/CARKaim/sdk/clipasswordsdk"
+)
+
+// credentialSource fetches the username and password the provider logs on
+// with. The username is empty if the source only provides a password.
+type credentialSource interface {
+	credentials(ctx context.Context, client *gopas.APIClient) (username string, password string, err error)
+}
+
+// newCredentialSource returns the credential source chosen by the
+// credential_source block of the provider configuration, or nil if the block
+// is not set.
+func newCredentialSource(d *schema.ResourceData) (credentialSource, error) {
+	v, ok := d.GetOk("credential_source")
+	if !ok || len(v.([]interface{})) == 0 || v.([]interface{})[0] == nil {
+		return nil, nil
+	}
+	source := v.([]interface{})[0].(map[string]interface{})
+
+	switch t := source["type"].(string); t {
+	case credentialSourceCCP:
+		s := &ccpSource{
+			url:   source["ccp_url"].(string),
+			appID: source["app_id"].(string),
+			query: source["query"].(string),
+		}
+		if s.url == "" || s.appID == "" || s.query == "" {
+			return nil, fmt.Errorf("ccp_url, app_id and query must be set to use the ccp credential source")
+		}
+		return s, nil
+	case credentialSourcePasswordSDK:
+		s := &passwordSDKSource{
+			path:  source["sdk_path"].(string),
+			appID: source["app_id"].(string),
+			query: source["query"].(string),
+		}
+		if s.appID == "" || s.query == "" {
+			return nil, fmt.Errorf("app_id and query must be set to use the password_sdk credential source")
+		}
+		return s, nil
+	case credentialSourceFile:
+		s := &fileSource{path: source["path"].(string)}
+		if s.path == "" {
+			return nil, fmt.Errorf("path must be set to use the file credential source")
+		}
+		return s, nil
+	case credentialSourceCommand:
+		s := &commandSource{}
+		for _, arg := range source["command"].([]interface{}) {
+			s.command = append(s.command, arg.(string))
+		}
+		if len(s.command) == 0 {
+			return nil, fmt.Errorf("command must be set to use the command credential source")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unsupported credential source %q", t)
+	}
+}
+
+// ccpSource fetches the credentials from the Central Credential Provider web
+// service. The HTTP client of the provider is used, so the CCP is called with
+// the same client certificate and trusted certificate authorities.
+type ccpSource struct {
+	url   string
+	appID string
+	query string
+}
+
+func (s *ccpSource) credentials(ctx context.Context, client *gopas.APIClient) (string, string, error) {
+	query := url.Values{
+		"AppID": {s.appID},
+		"Query": {s.query},
+	}
+	u := strings.TrimSuffix(s.url, "/") + "/AIMWebService/api/Accounts?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.GetConfig().HTTPClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var account struct {
+		Content   string `json:"Content"`
+		UserName  string `json:"UserName"`
+		ErrorCode string `json:"ErrorCode"`
+		ErrorMsg  string `json:"ErrorMsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil && resp.StatusCode < 300 {
+		return "", "", fmt.Errorf("decoding the Central Credential Provider response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return "", "", fmt.Errorf("fetching credentials from the Central Credential Provider: %s: %s %s", resp.Status, account.ErrorCode, account.ErrorMsg)
+	}
+	if account.Content == "" {
+		return "", "", fmt.Errorf("the Central Credential Provider returned no password for query %q", s.query)
+	}
+
+	return account.UserName, account.Content, nil
+}
+
+// passwordSDKSource fetches the password with the CLI Password SDK of a
+// Credential Provider installed on the machine running Terraform.
+type passwordSDKSource struct {
+	path  string
+	appID string
+	query string
+}
+
+func (s *passwordSDKSource) credentials(ctx context.Context, client *gopas.APIClient) (string, string, error) {
+	out, err := commandOutput(ctx, []string{
+		s.path, "GetPassword",
+		"-p", "AppDescs.AppID=" + s.appID,
+		"-p", "Query=" + s.query,
+		"-o", "Password",
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("fetching the password with the CLI Password SDK: %w", err)
+	}
+	if out == "" {
+		return "", "", fmt.Errorf("the CLI Password SDK returned no password for query %q", s.query)
+	}
+
+	return "", out, nil
+}
+
+// fileSource reads the password from a file, such as one written by a secrets
+// agent. A trailing newline is not part of the password.
+type fileSource struct {
+	path string
+}
+
+func (s *fileSource) credentials(ctx context.Context, client *gopas.APIClient) (string, string, error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading the password file: %w", err)
+	}
+
+	password := strings.TrimRight(string(b), "\r\n")
+	if password == "" {
+		return "", "", fmt.Errorf("the password file %s is empty", s.path)
+	}
+
+	return "", password, nil
+}
+
+// commandSource runs a command that prints the credentials as a JSON object
+// with Username and Password keys, like an AWS credential_process.
+type commandSource struct {
+	command []string
+}
+
+func (s *commandSource) credentials(ctx context.Context, client *gopas.APIClient) (string, string, error) {
+	out, err := commandOutput(ctx, s.command)
+	if err != nil {
+		return "", "", fmt.Errorf("running the credential command: %w", err)
+	}
+
+	var creds struct {
+		Username string `json:"Username"`
+		Password string `json:"Password"`
+	}
+	if err := json.Unmarshal([]byte(out), &creds); err != nil {
+		return "", "", fmt.Errorf("decoding the output of the credential command: %w", err)
+	}
+	if creds.Password == "" {
+		return "", "", fmt.Errorf("the credential command did not print a Password")
+	}
+
+	return creds.Username, creds.Password, nil
+}
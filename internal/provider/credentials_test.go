@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestNewCredentialSource(t *testing.T) {
+	cases := []struct {
+		name    string
+		source  map[string]interface{}
+		want    credentialSource
+		wantErr bool
+	}{
+		{
+			name:   "ccp",
+			source: map[string]interface{}{"type": "ccp", "ccp_url": "https://ccp.example.com", "app_id": "Terraform", "query": "Safe=S;Object=O"},
+			want:   &ccpSource{url: "https://ccp.example.com", appID: "Terraform", query: "Safe=S;Object=O"},
+		},
+		{
+			name:    "ccp without url",
+			source:  map[string]interface{}{"type": "ccp", "app_id": "Terraform", "query": "Safe=S;Object=O"},
+			wantErr: true,
+		},
+		{
+			name:   "password_sdk",
+			source: map[string]interface{}{"type": "password_sdk", "app_id": "Terraform", "query": "Safe=S;Object=O"},
+			want:   &passwordSDKSource{path: defaultPasswordSDKPath, appID: "Terraform", query: "Safe=S;Object=O"},
+		},
+		{
+			name:   "file",
+			source: map[string]interface{}{"type": "file", "path": "/run/secrets/pas"},
+			want:   &fileSource{path: "/run/secrets/pas"},
+		},
+		{
+			name:    "command without command",
+			source:  map[string]interface{}{"type": "command"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, New("dev")().Schema, map[string]interface{}{
+				"credential_source": []interface{}{c.source},
+			})
+			got, err := newCredentialSource(d)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", c.want) {
+				t.Errorf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCCPSource(t *testing.T) {
+	client, serverURL := testAuthClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query()
+		if q.Get("Query") == "Safe=S;Object=Empty" {
+			fmt.Fprint(w, `{"Content":"","UserName":"svc-terraform","Safe":"S"}`)
+			return
+		}
+		if r.URL.Path != "/AIMWebService/api/Accounts" || q.Get("AppID") != "Terraform" || q.Get("Query") != "Safe=S;Object=O" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"ErrorCode":"APPAP004E","ErrorMsg":"Password object matching query [Safe=S;Object=X] was not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Content":"s3cret","UserName":"svc-terraform","Safe":"S"}`)
+	})
+
+	s := &ccpSource{url: serverURL + "/", appID: "Terraform", query: "Safe=S;Object=O"}
+	username, password, err := s.credentials(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "svc-terraform" || password != "s3cret" {
+		t.Errorf("got %q, %q", username, password)
+	}
+
+	s.query = "Safe=S;Object=X"
+	if _, _, err := s.credentials(context.Background(), client); err == nil {
+		t.Error("expected an error for an account that was not found")
+	}
+
+	s.query = "Safe=S;Object=Empty"
+	if _, _, err := s.credentials(context.Background(), client); err == nil {
+		t.Error("expected an error for an account without a password")
+	}
+}
+
+func TestPasswordSDKSource(t *testing.T) {
+	sdk := filepath.Join(t.TempDir(), "clipasswordsdk")
+	script := `#!/bin/sh
+[ "$*" = "GetPassword -p AppDescs.AppID=Terraform -p Query=Safe=S;Object=Empty -o Password" ] && { echo; exit 0; }
+[ "$*" = "GetPassword -p AppDescs.AppID=Terraform -p Query=Safe=S;Object=O -o Password" ] || { echo "APPAP004E Password object not found" >&2; exit 1; }
+echo s3cret
+`
+	if err := os.WriteFile(sdk, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &passwordSDKSource{path: sdk, appID: "Terraform", query: "Safe=S;Object=O"}
+	_, password, err := s.credentials(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "s3cret" {
+		t.Errorf("got password %q", password)
+	}
+
+	s.query = "Safe=S;Object=X"
+	if _, _, err := s.credentials(context.Background(), nil); err == nil {
+		t.Error("expected an error for an account that was not found")
+	}
+
+	s.query = "Safe=S;Object=Empty"
+	if _, _, err := s.credentials(context.Background(), nil); err == nil {
+		t.Error("expected an error for an account without a password")
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("s3cret \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, password, err := (&fileSource{path: path}).credentials(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "s3cret " {
+		t.Errorf("got password %q", password)
+	}
+
+	for _, content := range []string{"", "\n", "\r\n"} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := (&fileSource{path: path}).credentials(context.Background(), nil); err == nil {
+			t.Errorf("expected an error for a password file containing %q", content)
+		}
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	cases := []struct {
+		name         string
+		output       string
+		wantUsername string
+		wantPassword string
+		wantErr      bool
+	}{
+		{name: "username and password", output: `{"Version":1,"Username":"svc-terraform","Password":"s3cret"}`, wantUsername: "svc-terraform", wantPassword: "s3cret"},
+		{name: "password", output: `{"Password":"s3cret"}`, wantPassword: "s3cret"},
+		{name: "no password", output: `{"Username":"svc-terraform"}`, wantErr: true},
+		{name: "not json", output: `s3cret`, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := &commandSource{command: []string{"echo", c.output}}
+			username, password, err := s.credentials(context.Background(), nil)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if username != c.wantUsername || password != c.wantPassword {
+				t.Errorf("got %q, %q", username, password)
+			}
+		})
+	}
+}
+
+func TestLogonAuthenticatorCredentialSource(t *testing.T) {
+	client, _ := testAuthClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `"session-token"`)
+	})
+
+	a := &logonAuthenticator{authType: "cyberark", source: &commandSource{command: []string{"echo", `{"Password":"s3cret"}`}}}
+	if _, err := a.authenticate(context.Background(), client); err == nil {
+		t.Error("expected an error without a username")
+	}
+
+	a.username = "svc-terraform"
+	if _, err := a.authenticate(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+}
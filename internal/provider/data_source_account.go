@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/umich-vci/gopas"
+)
+
+func dataSourceAccount() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source to look up a single account in CyberArk PAS",
+
+		ReadContext: dataSourceAccountRead,
+
+		Schema: map[string]*schema.Schema{
+			"safe_name": {
+				Description: "The name of the safe the account is in. This is required when looking up the account by `name`, `username` or `address`.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"name": {
+				Description:  "The name of the account.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				RequiredWith: []string{"safe_name"},
+				AtLeastOneOf: []string{"name", "username", "address", "search"},
+			},
+			"username": {
+				Description:  "The username of the account.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				RequiredWith: []string{"safe_name"},
+			},
+			"address": {
+				Description:  "The name or address of the machine where the account is used.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				RequiredWith: []string{"safe_name"},
+			},
+			"search": {
+				Description:   "Keywords to search for, separated by spaces. Exactly one account must match.",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"name", "username", "address"},
+			},
+			"search_type": {
+				Description:  "How `search` is matched. Valid values are `contains` and `startswith`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "contains",
+				ValidateFunc: validation.StringInSlice([]string{"contains", "startswith"}, false),
+			},
+			"platform_id": {
+				Description: "The ID of the platform of the account.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"secret_type": {
+				Description: "The type of the secret of the account.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"platform_account_properties": {
+				Description: "Additional properties of the account, as defined by its platform.",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"secret_management": dataSourceAccountSecretManagementSchema(),
+			"category_modification_time": {
+				Description: "When the account's properties were last modified, in Unix time.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"created_time": {
+				Description: "When the account was created",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// dataSourceAccountSecretManagementSchema is the read-only secret_management
+// block of the account data sources.
+func dataSourceAccountSecretManagementSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "How the CPM manages the secret of the account.",
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"automatic_management_enabled": {
+					Description: "Whether the CPM manages the secret of the account automatically.",
+					Type:        schema.TypeBool,
+					Computed:    true,
+				},
+				"manual_management_reason": {
+					Description: "Why the secret of the account is managed manually.",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				"status": {
+					Description: "The management status of the account.",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				"last_modified_time": {
+					Description: "When the secret was last modified, in Unix time.",
+					Type:        schema.TypeInt,
+					Computed:    true,
+				},
+				"last_reconciled_time": {
+					Description: "When the secret was last reconciled, in Unix time.",
+					Type:        schema.TypeInt,
+					Computed:    true,
+				},
+				"last_verified_time": {
+					Description: "When the secret was last verified, in Unix time.",
+					Type:        schema.TypeInt,
+					Computed:    true,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceAccountRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Get("safe_name").(string)
+	name := d.Get("name").(string)
+	username := d.Get("username").(string)
+	address := d.Get("address").(string)
+
+	search := d.Get("search").(string)
+
+	keywords := search
+	if keywords == "" {
+		keywords = strings.TrimSpace(strings.Join([]string{name, username, address}, " "))
+	}
+
+	accounts, resp, err := listAccounts(ctx, client, keywords, d.Get("search_type").(string), accountsFilter(safeName, 0), nil)
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	accounts = matchAccounts(accounts, name, username, address)
+
+	if len(accounts) == 0 {
+		return diag.Errorf("no account matched %s", describeAccountQuery(safeName, name, username, address, search))
+	}
+	if len(accounts) > 1 {
+		return diag.Errorf("%d accounts matched %s, but exactly one is required; narrow the search", len(accounts), describeAccountQuery(safeName, name, username, address, search))
+	}
+
+	account := accounts[0]
+
+	d.SetId(account.GetId())
+	d.Set("address", account.Address)
+	d.Set("category_modification_time", account.CategoryModificationTime)
+	d.Set("created_time", account.CreatedTime)
+	d.Set("name", account.Name)
+	d.Set("platform_account_properties", account.PlatformAccountProperties)
+	d.Set("platform_id", account.PlatformId)
+	d.Set("safe_name", account.SafeName)
+	d.Set("secret_management", flattenAccountSecretManagement(account.SecretManagement))
+	d.Set("secret_type", account.SecretType)
+	d.Set("username", account.UserName)
+
+	return nil
+}
+
+// matchAccounts returns the accounts whose name, username and address equal
+// the given values, ignoring case. Empty values match any account. The
+// Accounts search matches keywords anywhere, so results must be narrowed
+// before they can be treated as exact.
+func matchAccounts(accounts []gopas.AccountModel, name, username, address string) []gopas.AccountModel {
+	matches := []gopas.AccountModel{}
+	for _, a := range accounts {
+		if name != "" && !strings.EqualFold(a.GetName(), name) {
+			continue
+		}
+		if username != "" && !strings.EqualFold(a.GetUserName(), username) {
+			continue
+		}
+		if address != "" && !strings.EqualFold(a.GetAddress(), address) {
+			continue
+		}
+		matches = append(matches, a)
+	}
+	return matches
+}
+
+// describeAccountQuery describes the arguments used to look up an account for
+// error messages.
+func describeAccountQuery(safeName, name, username, address, search string) string {
+	parts := []string{}
+	for _, p := range []struct{ attr, value string }{
+		{"safe_name", safeName},
+		{"name", name},
+		{"username", username},
+		{"address", address},
+		{"search", search},
+	} {
+		if p.value != "" {
+			parts = append(parts, fmt.Sprintf("%s %q", p.attr, p.value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
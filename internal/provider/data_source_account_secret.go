@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceAccountSecret() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source to retrieve the secret of an account in CyberArk PAS. Every read is a retrieval that is audited by the Vault, and the secret is stored in the Terraform state.",
+
+		ReadContext: dataSourceAccountSecretRead,
+
+		Schema: map[string]*schema.Schema{
+			"account_id": {
+				Description:  "The ID of the account to retrieve the secret of.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"reason": {
+				Description: "The reason for retrieving the secret, recorded in the Vault audit.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"ticketing_system_name": {
+				Description:  "The name of the ticketing system that `ticket_id` belongs to.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"ticket_id"},
+			},
+			"ticket_id": {
+				Description:  "The ID of the ticket that authorizes the retrieval.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"ticketing_system_name"},
+			},
+			"version": {
+				Description:  "The version of the secret to retrieve. If not specified, the current version is retrieved.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"action_type": {
+				Description:  "The action the secret is retrieved for. Valid values are `show`, `copy` and `connect`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"show", "copy", "connect"}, false),
+			},
+			"value": {
+				Description: "The secret of the account.",
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+			},
+		},
+	}
+}
+
+func dataSourceAccountSecretRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Get("account_id").(string)
+
+	body := accountSecretRetrieveRequest{
+		Reason:              d.Get("reason").(string),
+		TicketingSystemName: d.Get("ticketing_system_name").(string),
+		TicketID:            d.Get("ticket_id").(string),
+		Version:             d.Get("version").(int),
+		ActionType:          d.Get("action_type").(string),
+	}
+
+	secret, resp, err := retrieveAccountSecret(ctx, client, id, body)
+	if err != nil {
+		return retrieveAccountSecretErr(id, resp, err)
+	}
+
+	d.SetId(id)
+	d.Set("value", secret)
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/umich-vci/gopas"
+)
+
+func TestRetrieveAccountSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/PasswordVault/api/Accounts/1_1/Password/Retrieve":
+			if body["reason"] != "deploy" || body["TicketingSystemName"] != "ServiceNow" || body["TicketId"] != "CHG0001" {
+				t.Errorf("unexpected body %v", body)
+			}
+			fmt.Fprint(w, `"s3cr\"et"`)
+		case "/PasswordVault/api/Accounts/1_2/Password/Retrieve":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"ErrorCode":"PASWS013E","ErrorMessage":"Retrieving the password requires a confirmed request."}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"ErrorCode":"PASWS004E","ErrorMessage":"Account was not found."}`)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := gopas.NewConfiguration()
+	config.Host = u.Host
+	config.Scheme = u.Scheme
+	client := *gopas.NewAPIClient(config)
+
+	body := accountSecretRetrieveRequest{Reason: "deploy", TicketingSystemName: "ServiceNow", TicketID: "CHG0001"}
+
+	secret, _, err := retrieveAccountSecret(context.Background(), client, "1_1", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != `s3cr"et` {
+		t.Errorf("got secret %q", secret)
+	}
+
+	_, resp, err := retrieveAccountSecret(context.Background(), client, "1_2", body)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	diags := retrieveAccountSecretErr("1_2", resp, err)
+	if len(diags) != 1 || diags[0].Summary != "Dual control request required" {
+		t.Errorf("got diagnostics %v", diags)
+	}
+
+	_, resp, err = retrieveAccountSecret(context.Background(), client, "1_3", body)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if isRequestRequiredErr(resp) {
+		t.Error("a missing account was reported as requiring a request")
+	}
+}
+
+func TestAccDataSourceAccountSecret(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheckWriteOnly(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceAccountSecret(safeName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.pas_account_secret.test", "value", "Initial-Passw0rd"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceAccountSecret(safeName string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_account" "test" {
+  safe_name   = pas_safe.test.safe_name
+  platform_id = "UnixSSH"
+  address     = "host.example.com"
+  username    = "svc_terraform"
+  secret      = "Initial-Passw0rd"
+}
+
+data "pas_account_secret" "test" {
+  account_id = pas_account.test.id
+  reason     = "Terraform acceptance test"
+}
+`, safeName)
+}
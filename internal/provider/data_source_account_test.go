@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/umich-vci/gopas"
+)
+
+func TestMatchAccounts(t *testing.T) {
+	account := func(id, name, username, address string) gopas.AccountModel {
+		a := *gopas.NewAccountModel("UnixSSH", "Linux")
+		a.Id = &id
+		a.Name = &name
+		a.UserName = &username
+		a.Address = &address
+		return a
+	}
+
+	accounts := []gopas.AccountModel{
+		account("1_1", "Operating System-UnixSSH-host1.example.com-root", "root", "host1.example.com"),
+		account("1_2", "Operating System-UnixSSH-host2.example.com-root", "root", "host2.example.com"),
+		account("1_3", "Operating System-UnixSSH-host1.example.com-rootadmin", "rootadmin", "host1.example.com"),
+	}
+
+	cases := []struct {
+		name, username, address string
+		want                    []string
+	}{
+		{"operating system-unixssh-host1.example.com-root", "", "", []string{"1_1"}},
+		{"", "root", "", []string{"1_1", "1_2"}},
+		{"", "root", "HOST1.example.com", []string{"1_1"}},
+		{"", "", "host1.example.com", []string{"1_1", "1_3"}},
+		{"", "admin", "", []string{}},
+	}
+
+	for _, c := range cases {
+		got := []string{}
+		for _, a := range matchAccounts(accounts, c.name, c.username, c.address) {
+			got = append(got, a.GetId())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("matchAccounts(%q, %q, %q) = %v, want %v", c.name, c.username, c.address, got, c.want)
+		}
+	}
+}
+
+func TestAccDataSourceAccount(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceAccount(safeName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.pas_account.by_name", "id", "pas_account.test", "id"),
+					resource.TestCheckResourceAttrPair("data.pas_account.by_username", "id", "pas_account.test", "id"),
+					resource.TestCheckResourceAttr("data.pas_account.by_name", "platform_id", "UnixSSH"),
+					resource.TestCheckResourceAttr("data.pas_account.by_name", "platform_account_properties.Port", "22"),
+					resource.TestCheckResourceAttrSet("data.pas_account.by_name", "secret_management.0.status"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceAccount(safeName string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_account" "test" {
+  safe_name   = pas_safe.test.safe_name
+  platform_id = "UnixSSH"
+  address     = "host.example.com"
+  username    = "svc_terraform"
+
+  platform_account_properties = {
+    Port = "22"
+  }
+}
+
+data "pas_account" "by_name" {
+  safe_name = pas_safe.test.safe_name
+  name      = pas_account.test.name
+}
+
+data "pas_account" "by_username" {
+  safe_name = pas_safe.test.safe_name
+  username  = pas_account.test.username
+  address   = pas_account.test.address
+}
+`, safeName)
+}
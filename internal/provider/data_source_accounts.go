@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceAccounts() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source to list the accounts matching a search in CyberArk PAS",
+
+		ReadContext: dataSourceAccountsRead,
+
+		Schema: map[string]*schema.Schema{
+			"search": {
+				Description: "Keywords to search for, separated by spaces.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"search_type": {
+				Description:  "How `search` is matched. Valid values are `contains` and `startswith`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "contains",
+				ValidateFunc: validation.StringInSlice([]string{"contains", "startswith"}, false),
+			},
+			"safe_name": {
+				Description: "Only list accounts in this safe.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"modified_since": {
+				Description:  "Only list accounts modified at or after this time, in Unix time.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"sort": {
+				Description: "The properties to sort the accounts by, each optionally followed by `asc` or `desc`, such as `userName desc`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsNotEmpty,
+				},
+			},
+			"accounts": {
+				Description: "The accounts that matched.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Description: "The ID of the account.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"safe_name": {
+							Description: "The name of the safe the account is in.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"name": {
+							Description: "The name of the account.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"username": {
+							Description: "The username of the account.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"address": {
+							Description: "The name or address of the machine where the account is used.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"platform_id": {
+							Description: "The ID of the platform of the account.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"secret_type": {
+							Description: "The type of the secret of the account.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"platform_account_properties": {
+							Description: "Additional properties of the account, as defined by its platform.",
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"secret_management": dataSourceAccountSecretManagementSchema(),
+						"category_modification_time": {
+							Description: "When the account's properties were last modified, in Unix time.",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"created_time": {
+							Description: "When the account was created",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceAccountsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	search := d.Get("search").(string)
+	searchType := d.Get("search_type").(string)
+	filter := accountsFilter(d.Get("safe_name").(string), d.Get("modified_since").(int))
+
+	sort := []string{}
+	for _, s := range d.Get("sort").([]interface{}) {
+		sort = append(sort, s.(string))
+	}
+
+	accounts, resp, err := listAccounts(ctx, client, search, searchType, filter, sort)
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	list := make([]interface{}, 0, len(accounts))
+	for _, a := range accounts {
+		list = append(list, map[string]interface{}{
+			"id":                          a.GetId(),
+			"safe_name":                   a.SafeName,
+			"name":                        a.GetName(),
+			"username":                    a.GetUserName(),
+			"address":                     a.GetAddress(),
+			"platform_id":                 a.PlatformId,
+			"secret_type":                 a.GetSecretType(),
+			"platform_account_properties": a.GetPlatformAccountProperties(),
+			"secret_management":           flattenAccountSecretManagement(a.SecretManagement),
+			"category_modification_time":  int(a.GetCategoryModificationTime()),
+			"created_time":                int(a.GetCreatedTime()),
+		})
+	}
+
+	query := strings.Join(append([]string{search, searchType, filter}, sort...), "\n")
+	sum := sha1.Sum([]byte(query))
+	d.SetId(hex.EncodeToString(sum[:]))
+
+	if err := d.Set("accounts", list); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// accountsFilter returns the Accounts API filter for the accounts in a safe
+// and modified since a time. Zero values are left out of the filter.
+func accountsFilter(safeName string, modifiedSince int) string {
+	filters := []string{}
+	if safeName != "" {
+		filters = append(filters, fmt.Sprintf("safeName eq %s", safeName))
+	}
+	if modifiedSince > 0 {
+		filters = append(filters, fmt.Sprintf("modificationTime gte %d", modifiedSince))
+	}
+	return strings.Join(filters, " AND ")
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/umich-vci/gopas"
+)
+
+func TestAccountsFilter(t *testing.T) {
+	cases := []struct {
+		safeName      string
+		modifiedSince int
+		want          string
+	}{
+		{"", 0, ""},
+		{"Linux", 0, "safeName eq Linux"},
+		{"", 1650000000, "modificationTime gte 1650000000"},
+		{"Linux", 1650000000, "safeName eq Linux AND modificationTime gte 1650000000"},
+	}
+
+	for _, c := range cases {
+		if got := accountsFilter(c.safeName, c.modifiedSince); got != c.want {
+			t.Errorf("accountsFilter(%q, %d) = %q, want %q", c.safeName, c.modifiedSince, got, c.want)
+		}
+	}
+}
+
+func TestListAccounts(t *testing.T) {
+	const total = 2500
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		accounts := []gopas.AccountModel{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			a := *gopas.NewAccountModel("UnixSSH", "Linux")
+			id := fmt.Sprintf("1_%d", i)
+			a.Id = &id
+			accounts = append(accounts, a)
+		}
+
+		count := int32(total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(gopas.GetAccountsResponse{Value: &accounts, Count: &count})
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	config := gopas.NewConfiguration()
+	config.Host = u.Host
+	config.Scheme = u.Scheme
+	client := gopas.NewAPIClient(config)
+
+	accounts, _, err := listAccounts(context.Background(), *client, "", "", "safeName eq Linux", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(accounts) != total {
+		t.Fatalf("got %d accounts, want %d", len(accounts), total)
+	}
+	for i, a := range accounts {
+		if want := fmt.Sprintf("1_%d", i); a.GetId() != want {
+			t.Fatalf("account %d has ID %q, want %q", i, a.GetId(), want)
+		}
+	}
+}
+
+func TestAccDataSourceAccounts(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceAccounts(safeName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.pas_accounts.test", "accounts.#", "3"),
+					resource.TestCheckResourceAttr("data.pas_accounts.test", "accounts.0.username", "svc_a"),
+					resource.TestCheckResourceAttr("data.pas_accounts.test", "accounts.2.username", "svc_c"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceAccounts(safeName string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_account" "test" {
+  count = 3
+
+  safe_name   = pas_safe.test.safe_name
+  platform_id = "UnixSSH"
+  address     = "host.example.com"
+  username    = ["svc_c", "svc_a", "svc_b"][count.index]
+}
+
+data "pas_accounts" "test" {
+  safe_name = pas_safe.test.safe_name
+  sort      = ["userName asc"]
+
+  depends_on = [pas_account.test]
+}
+`, safeName)
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func newEphemeralAccountSecret() ephemeral.EphemeralResource {
+	return &ephemeralAccountSecret{}
+}
+
+// ephemeralAccountSecret retrieves the secret of an account without storing
+// it in the plan or state.
+type ephemeralAccountSecret struct {
+	client *apiClient
+}
+
+type ephemeralAccountSecretModel struct {
+	AccountID           types.String `tfsdk:"account_id"`
+	Reason              types.String `tfsdk:"reason"`
+	TicketingSystemName types.String `tfsdk:"ticketing_system_name"`
+	TicketID            types.String `tfsdk:"ticket_id"`
+	Version             types.Int64  `tfsdk:"version"`
+	ActionType          types.String `tfsdk:"action_type"`
+	Value               types.String `tfsdk:"value"`
+}
+
+var _ ephemeral.EphemeralResourceWithConfigure = &ephemeralAccountSecret{}
+var _ ephemeral.EphemeralResourceWithValidateConfig = &ephemeralAccountSecret{}
+
+func (r *ephemeralAccountSecret) Metadata(ctx context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_account_secret"
+}
+
+func (r *ephemeralAccountSecret) Schema(ctx context.Context, req ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Ephemeral resource to retrieve the secret of an account in CyberArk PAS. Every open is a retrieval that is audited by the Vault. Unlike the `pas_account_secret` data source, the secret is never stored in the plan or state. Ephemeral resources require Terraform 1.10 or later.",
+
+		Attributes: map[string]schema.Attribute{
+			"account_id": schema.StringAttribute{
+				MarkdownDescription: "The ID of the account to retrieve the secret of.",
+				Required:            true,
+			},
+			"reason": schema.StringAttribute{
+				MarkdownDescription: "The reason for retrieving the secret, recorded in the Vault audit.",
+				Optional:            true,
+			},
+			"ticketing_system_name": schema.StringAttribute{
+				MarkdownDescription: "The name of the ticketing system that `ticket_id` belongs to.",
+				Optional:            true,
+			},
+			"ticket_id": schema.StringAttribute{
+				MarkdownDescription: "The ID of the ticket that authorizes the retrieval.",
+				Optional:            true,
+			},
+			"version": schema.Int64Attribute{
+				MarkdownDescription: "The version of the secret to retrieve. If not specified, the current version is retrieved.",
+				Optional:            true,
+			},
+			"action_type": schema.StringAttribute{
+				MarkdownDescription: "The action the secret is retrieved for. Valid values are `show`, `copy` and `connect`.",
+				Optional:            true,
+			},
+			"value": schema.StringAttribute{
+				MarkdownDescription: "The secret of the account.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+		},
+	}
+}
+
+func (r *ephemeralAccountSecret) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	r.client = req.ProviderData.(*apiClient)
+}
+
+func (r *ephemeralAccountSecret) ValidateConfig(ctx context.Context, req ephemeral.ValidateConfigRequest, resp *ephemeral.ValidateConfigResponse) {
+	var config ephemeralAccountSecretModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if config.AccountID.ValueString() == "" && !config.AccountID.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(path.Root("account_id"), "Invalid account_id", "account_id must not be empty.")
+	}
+
+	if !config.Version.IsNull() && !config.Version.IsUnknown() && config.Version.ValueInt64() < 1 {
+		resp.Diagnostics.AddAttributeError(path.Root("version"), "Invalid version", "version must be at least 1.")
+	}
+
+	switch config.ActionType.ValueString() {
+	case "", "show", "copy", "connect":
+	default:
+		resp.Diagnostics.AddAttributeError(path.Root("action_type"), "Invalid action_type", "action_type must be one of show, copy or connect.")
+	}
+
+	if config.TicketingSystemName.IsNull() != config.TicketID.IsNull() {
+		resp.Diagnostics.AddError("Incomplete ticket", "ticketing_system_name and ticket_id must be set together.")
+	}
+}
+
+func (r *ephemeralAccountSecret) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Provider not configured", "The CyberArk PAS client was not configured before the account secret was opened. This is a bug in the provider.")
+		return
+	}
+
+	var config ephemeralAccountSecretModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	id := config.AccountID.ValueString()
+
+	body := accountSecretRetrieveRequest{
+		Reason:              config.Reason.ValueString(),
+		TicketingSystemName: config.TicketingSystemName.ValueString(),
+		TicketID:            config.TicketID.ValueString(),
+		Version:             int(config.Version.ValueInt64()),
+		ActionType:          config.ActionType.ValueString(),
+	}
+
+	secret, httpResp, err := retrieveAccountSecret(ctx, r.client.Client, id, body)
+	if err != nil {
+		appendDiags(&resp.Diagnostics, retrieveAccountSecretErr(id, httpResp, err))
+		return
+	}
+
+	config.Value = types.StringValue(secret)
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &config)...)
+}
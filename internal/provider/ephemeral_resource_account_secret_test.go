@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestEphemeralAccountSecretOpenWithoutClient(t *testing.T) {
+	ctx := context.Background()
+	r := &ephemeralAccountSecret{}
+
+	var schemaResp ephemeral.SchemaResponse
+	r.Schema(ctx, ephemeral.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+	objectType := s.Type().TerraformType(ctx).(tftypes.Object)
+
+	values := map[string]tftypes.Value{}
+	for name, attrType := range objectType.AttributeTypes {
+		values[name] = tftypes.NewValue(attrType, nil)
+	}
+	values["account_id"] = tftypes.NewValue(tftypes.String, "1_1")
+
+	resp := &ephemeral.OpenResponse{Result: tfsdk.EphemeralResultData{Schema: s, Raw: tftypes.NewValue(objectType, nil)}}
+	r.Open(ctx, ephemeral.OpenRequest{Config: tfsdk.Config{Schema: s, Raw: tftypes.NewValue(objectType, values)}}, resp)
+
+	if !resp.Diagnostics.HasError() || resp.Diagnostics[0].Summary() != "Provider not configured" {
+		t.Errorf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+}
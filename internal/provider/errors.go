@@ -0,0 +1,305 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// pasError is the error body returned by the PVWA REST API.
+type pasError struct {
+	ErrorCode    string `json:"ErrorCode"`
+	ErrorMessage string `json:"ErrorMessage"`
+}
+
+// responseError decodes the PVWA error body of resp, leaving the body readable
+// for later callers such as apiErrorDiags.
+func responseError(resp *http.Response) pasError {
+	var e pasError
+	if resp == nil || resp.Body == nil {
+		return e
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(strings.NewReader(string(b)))
+	if err != nil {
+		return e
+	}
+
+	json.Unmarshal(b, &e)
+	return e
+}
+
+// apiError is a failed request to the PVWA. resp is nil if no response was
+// received, for example when the server could not be resolved or its
+// certificate was not trusted.
+type apiError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	pasError
+
+	err error
+}
+
+// newAPIError returns the apiError for a request that failed with resp and
+// err. The PVWA error code and message are parsed from the body; a body that
+// is not a PVWA error is used as the message. Secrets sent in the request,
+// or found in the body, are redacted from the message.
+func newAPIError(resp *http.Response, err error) *apiError {
+	e := &apiError{err: err}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		e.Method = strings.ToUpper(urlErr.Op)
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			e.Path = u.Path
+		}
+		e.err = urlErr.Err
+	}
+
+	if resp == nil {
+		return e
+	}
+
+	e.StatusCode = resp.StatusCode
+	e.Status = resp.Status
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Path = resp.Request.URL.Path
+	}
+
+	if resp.Body != nil {
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(strings.NewReader(string(b)))
+
+		if json.Unmarshal(b, &e.pasError) != nil || e.ErrorMessage == "" {
+			e.ErrorMessage = strings.TrimSpace(string(b))
+		}
+	}
+
+	e.ErrorMessage = truncateErrorMessage(redact(e.ErrorMessage, sentSecrets(resp.Request)))
+
+	return e
+}
+
+// truncateErrorMessage cuts message to at most maxErrorMessageLength bytes,
+// without splitting a UTF-8 character.
+func truncateErrorMessage(message string) string {
+	if len(message) <= maxErrorMessageLength {
+		return message
+	}
+
+	cut := maxErrorMessageLength
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+
+	return message[:cut] + "..."
+}
+
+// maxErrorMessageLength bounds the message of an apiError, since a proxy or
+// IIS error page can be long and is rarely useful past its start.
+const maxErrorMessageLength = 1000
+
+func (e *apiError) Error() string {
+	var b strings.Builder
+	if e.Method != "" {
+		fmt.Fprintf(&b, "%s %s: ", e.Method, e.Path)
+	}
+
+	switch {
+	case e.Status == "":
+		fmt.Fprintf(&b, "%v", e.err)
+	case e.ErrorCode != "":
+		fmt.Fprintf(&b, "%s: %s: %s", e.Status, e.ErrorCode, e.ErrorMessage)
+	case e.ErrorMessage != "":
+		fmt.Fprintf(&b, "%s: %s", e.Status, e.ErrorMessage)
+	default:
+		b.WriteString(e.Status)
+	}
+
+	return b.String()
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// summary returns an actionable summary of the error, from its PVWA error
+// code if it is a known one, or else from its status.
+func (e *apiError) summary() string {
+	if s, ok := knownErrorCodes[e.ErrorCode]; ok {
+		return s
+	}
+
+	switch {
+	case e.Status == "":
+		return "Could not reach the CyberArk PAS server"
+	case e.StatusCode == http.StatusBadRequest:
+		return "The CyberArk PAS server rejected the request as invalid"
+	case e.StatusCode == http.StatusUnauthorized:
+		return "Authentication to the CyberArk PAS server failed"
+	case e.StatusCode == http.StatusForbidden:
+		return "The user of the provider is not authorized to do this"
+	case e.StatusCode == http.StatusNotFound:
+		return "The object was not found in CyberArk PAS"
+	case e.StatusCode == http.StatusConflict:
+		return "The object already exists in CyberArk PAS"
+	case e.StatusCode == http.StatusTooManyRequests:
+		return "The CyberArk PAS server is throttling requests"
+	case e.StatusCode >= 500 && strings.HasPrefix(e.ErrorCode, "ITATS"):
+		return "The Vault rejected the request"
+	case e.StatusCode >= 500:
+		return "The CyberArk PAS server failed to process the request"
+	default:
+		return "Request to the CyberArk PAS server failed"
+	}
+}
+
+// knownErrorCodes maps PVWA error codes that have a clear remedy to their
+// summaries. PASWS codes are raised by the PVWA web services and ITATS codes
+// by the Vault. Other codes are summarized from the status.
+var knownErrorCodes = map[string]string{
+	"PASWS006E": "The PVWA rejected the session token; check the auth settings of the provider, and that a token given to the token method has not expired",
+	"PASWS013E": "The object was not found in CyberArk PAS; check its name, and that the user of the provider is a member of its safe",
+	"PASWS027E": "The object already exists in CyberArk PAS; import it with terraform import instead of creating it",
+	"PASWS167E": "The request has invalid parameters; check the arguments named in the error detail",
+	"ITATS004E": "Authentication to the Vault failed; check the username, password and auth_type",
+	"ITATS542I": "The RADIUS server requires a one-time password; set radius_otp or otp_command",
+}
+
+// apiErrorDiags returns the diagnostics for a request to the PVWA that failed
+// with resp and err. resp may be nil.
+func apiErrorDiags(resp *http.Response, err error) diag.Diagnostics {
+	e := newAPIError(resp, err)
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  e.summary(),
+		Detail:   e.Error(),
+	}}
+}
+
+// errorDiags returns the diagnostics for err, summarized like apiErrorDiags
+// if it was caused by a failed request to the PVWA.
+func errorDiags(err error) diag.Diagnostics {
+	var e *apiError
+	if !errors.As(err, &e) {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  e.summary(),
+		Detail:   err.Error(),
+	}}
+}
+
+// isNotFound reports whether a request failed because the object does not
+// exist.
+func isNotFound(resp *http.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusNotFound
+}
+
+// secretFields are the request fields whose values are secrets, in lower
+// case.
+var secretFields = map[string]bool{
+	"secret":         true,
+	"password":       true,
+	"newcredentials": true,
+	"client_secret":  true,
+	"samlresponse":   true,
+}
+
+// secretFieldPattern matches a secret field echoed in a JSON body.
+var secretFieldPattern = regexp.MustCompile(`(?i)"(secret|password|newcredentials|content|access_token|client_secret)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+// minRedactedLength is the length below which a sent secret is not redacted,
+// since replacing every occurrence of a very short string mangles the
+// message without hiding anything.
+const minRedactedLength = 4
+
+// redact removes secrets from an error message: the values of secret fields,
+// and any of secrets.
+func redact(msg string, secrets []string) string {
+	msg = secretFieldPattern.ReplaceAllString(msg, `"$1":"[REDACTED]"`)
+
+	// Replace longer secrets first, in case one contains another.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, s := range secrets {
+		if len(s) >= minRedactedLength {
+			msg = strings.ReplaceAll(msg, s, "[REDACTED]")
+		}
+	}
+
+	return msg
+}
+
+// sentSecrets returns the values of the secret fields in the body of req, a
+// JSON or form encoded body that can be read again, as it can for the
+// requests of gopas and apiRequest. JSON patch operations on a secret path
+// are included.
+func sentSecrets(req *http.Request) []string {
+	if req == nil || req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	b, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil
+	}
+
+	var secrets []string
+	var v interface{}
+	if json.Unmarshal(b, &v) == nil {
+		collectSecrets(v, &secrets)
+		return secrets
+	}
+
+	if form, err := url.ParseQuery(string(b)); err == nil {
+		for k, values := range form {
+			if secretFields[strings.ToLower(k)] {
+				secrets = append(secrets, values...)
+			}
+		}
+	}
+
+	return secrets
+}
+
+func collectSecrets(v interface{}, secrets *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if path, ok := v["path"].(string); ok && secretFields[strings.ToLower(strings.Trim(path, "/"))] {
+			if s, ok := v["value"].(string); ok {
+				*secrets = append(*secrets, s)
+			}
+		}
+		for k, value := range v {
+			if s, ok := value.(string); ok && secretFields[strings.ToLower(k)] {
+				*secrets = append(*secrets, s)
+				continue
+			}
+			collectSecrets(value, secrets)
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectSecrets(value, secrets)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// testErrorResponse sends a request with body to a server that responds with
+// status and respBody, and returns the response.
+func testErrorResponse(t *testing.T, method, contentType, body string, status int, respBody string) *http.Response {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, respBody)
+	}))
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequest(method, server.URL+"/PasswordVault/api/Accounts", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestNewAPIError(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		status      int
+		respBody    string
+		wantSummary string
+		wantDetail  string
+		notDetail   string
+	}{
+		{
+			name:        "pvwa error",
+			body:        `{"name":"a"}`,
+			status:      http.StatusBadRequest,
+			respBody:    `{"ErrorCode":"PASWS167E","ErrorMessage":"There are some invalid parameters"}`,
+			wantSummary: "The request has invalid parameters; check the arguments named in the error detail",
+			wantDetail:  "POST /PasswordVault/api/Accounts: 400 Bad Request: PASWS167E: There are some invalid parameters",
+		},
+		{
+			name:        "conflict",
+			body:        `{"safeName":"Linux"}`,
+			status:      http.StatusConflict,
+			respBody:    `{"ErrorCode":"PASWS027E","ErrorMessage":"Safe Linux already exists."}`,
+			wantSummary: "The object already exists in CyberArk PAS; import it with terraform import instead of creating it",
+		},
+		{
+			name:        "unknown code",
+			body:        `{"name":"a"}`,
+			status:      http.StatusForbidden,
+			respBody:    `{"ErrorCode":"PASWS999E","ErrorMessage":"Not allowed"}`,
+			wantSummary: "The user of the provider is not authorized to do this",
+			wantDetail:  "PASWS999E: Not allowed",
+		},
+		{
+			name:        "vault error",
+			body:        `{"name":"a"}`,
+			status:      http.StatusInternalServerError,
+			respBody:    `{"ErrorCode":"ITATS999E","ErrorMessage":"Something failed"}`,
+			wantSummary: "The Vault rejected the request",
+		},
+		{
+			name:        "not json",
+			status:      http.StatusBadGateway,
+			respBody:    "<html>Bad Gateway</html>\n",
+			wantSummary: "The CyberArk PAS server failed to process the request",
+			wantDetail:  "502 Bad Gateway: <html>Bad Gateway</html>",
+		},
+		{
+			name:        "secret echoed",
+			body:        `{"name":"a","secret":"hunter22"}`,
+			status:      http.StatusBadRequest,
+			respBody:    `{"ErrorCode":"PASWS999E","ErrorMessage":"Password hunter22 does not meet the policy"}`,
+			wantDetail:  "Password [REDACTED] does not meet the policy",
+			notDetail:   "hunter22",
+			wantSummary: "The CyberArk PAS server rejected the request as invalid",
+		},
+		{
+			name:       "secret in patch",
+			body:       `[{"op":"replace","path":"/secret","value":"hunter22"}]`,
+			status:     http.StatusBadRequest,
+			respBody:   `{"ErrorCode":"PASWS999E","ErrorMessage":"Invalid value hunter22"}`,
+			notDetail:  "hunter22",
+			wantDetail: "Invalid value [REDACTED]",
+		},
+		{
+			name:        "secret in form",
+			body:        url.Values{"SAMLResponse": {"PHNhbWxwOlJlc3BvbnNl"}}.Encode(),
+			contentType: "application/x-www-form-urlencoded",
+			status:      http.StatusUnauthorized,
+			respBody:    `Invalid SAML response PHNhbWxwOlJlc3BvbnNl`,
+			notDetail:   "PHNhbWxwOlJlc3BvbnNl",
+			wantSummary: "Authentication to the CyberArk PAS server failed",
+		},
+		{
+			name:       "secret field in body",
+			status:     http.StatusInternalServerError,
+			respBody:   `{"request":{"userName":"u","Password":"p@ss"}}`,
+			wantDetail: `"Password":"[REDACTED]"`,
+			notDetail:  "p@ss",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.contentType == "" {
+				c.contentType = "application/json"
+			}
+			resp := testErrorResponse(t, http.MethodPost, c.contentType, c.body, c.status, c.respBody)
+
+			diags := apiErrorDiags(resp, errors.New(resp.Status))
+			if len(diags) != 1 {
+				t.Fatalf("got %d diagnostics", len(diags))
+			}
+			if c.wantSummary != "" && diags[0].Summary != c.wantSummary {
+				t.Errorf("got summary %q, want %q", diags[0].Summary, c.wantSummary)
+			}
+			if !strings.Contains(diags[0].Detail, c.wantDetail) {
+				t.Errorf("got detail %q, want it to contain %q", diags[0].Detail, c.wantDetail)
+			}
+			if c.notDetail != "" && strings.Contains(diags[0].Detail, c.notDetail) {
+				t.Errorf("detail %q contains %q", diags[0].Detail, c.notDetail)
+			}
+		})
+	}
+}
+
+func TestTruncateErrorMessage(t *testing.T) {
+	message := strings.Repeat("a", maxErrorMessageLength-1) + strings.Repeat("é", 10)
+
+	got := truncateErrorMessage(message)
+	if !utf8.ValidString(got) {
+		t.Errorf("truncated message is not valid UTF-8: %q", got[len(got)-8:])
+	}
+	if want := strings.Repeat("a", maxErrorMessageLength-1) + "..."; got != want {
+		t.Errorf("got message ending in %q, want it to end in %q", got[len(got)-8:], want[len(want)-8:])
+	}
+
+	if got := truncateErrorMessage("short"); got != "short" {
+		t.Errorf("got %q, want the message unchanged", got)
+	}
+}
+
+func TestAPIErrorWithoutResponse(t *testing.T) {
+	_, err := http.Get("http://pvwa.invalid/PasswordVault/api/Safes")
+	if err == nil {
+		t.Skip("pvwa.invalid resolved")
+	}
+
+	diags := apiErrorDiags(nil, err)
+	if diags[0].Summary != "Could not reach the CyberArk PAS server" {
+		t.Errorf("got summary %q", diags[0].Summary)
+	}
+	if !strings.HasPrefix(diags[0].Detail, "GET /PasswordVault/api/Safes: ") {
+		t.Errorf("got detail %q", diags[0].Detail)
+	}
+
+	diags = apiErrorDiags(nil, errors.New("connection reset"))
+	if diags[0].Detail != "connection reset" {
+		t.Errorf("got detail %q", diags[0].Detail)
+	}
+
+	if isNotFound(nil) {
+		t.Error("a missing response is not a 404")
+	}
+}
+
+func TestErrorDiags(t *testing.T) {
+	resp := testErrorResponse(t, http.MethodPost, "application/json", `{"password":"hunter22"}`, http.StatusUnauthorized, `{"ErrorCode":"ITATS004E","ErrorMessage":"Authentication failure for User [u]."}`)
+
+	diags := errorDiags(fmt.Errorf("logging on: %w", newAPIError(resp, errors.New(resp.Status))))
+	if diags[0].Summary != knownErrorCodes["ITATS004E"] {
+		t.Errorf("got summary %q", diags[0].Summary)
+	}
+	if !strings.HasPrefix(diags[0].Detail, "logging on: POST ") {
+		t.Errorf("got detail %q", diags[0].Detail)
+	}
+
+	diags = errorDiags(errors.New("no command given"))
+	if diags[0].Summary != "no command given" {
+		t.Errorf("got summary %q", diags[0].Summary)
+	}
+}
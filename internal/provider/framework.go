@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	fwprovider "github.com/hashicorp/terraform-plugin-framework/provider"
+	fwschema "github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-mux/tf5muxserver"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// ProviderServer returns a function that serves the provider. Resources and
+// data sources are served by the SDK provider returned by New. Ephemeral
+// resources, which the SDK does not support, are served by a
+// terraform-plugin-framework provider that shares the SDK provider's client.
+func ProviderServer(version string) func() (tfprotov5.ProviderServer, error) {
+	return func() (tfprotov5.ProviderServer, error) {
+		p := New(version)()
+
+		servers := []func() tfprotov5.ProviderServer{
+			// The SDK provider must come first so that it is configured
+			// before the framework provider asks it for its client.
+			p.GRPCProvider,
+			func() tfprotov5.ProviderServer {
+				return frameworkServer{providerserver.NewProtocol5(&frameworkProvider{sdk: p, version: version})()}
+			},
+		}
+
+		mux, err := tf5muxserver.NewMuxServer(context.Background(), servers...)
+		if err != nil {
+			return nil, err
+		}
+
+		return mux.ProviderServer(), nil
+	}
+}
+
+// frameworkServer serves the framework provider without a provider schema of
+// its own. The provider is configured through the SDK provider's schema, and
+// muxed providers must otherwise declare identical provider schemas.
+type frameworkServer struct {
+	tfprotov5.ProviderServer
+}
+
+func (s frameworkServer) GetProviderSchema(ctx context.Context, req *tfprotov5.GetProviderSchemaRequest) (*tfprotov5.GetProviderSchemaResponse, error) {
+	resp, err := s.ProviderServer.GetProviderSchema(ctx, req)
+	if resp != nil {
+		resp.Provider = nil
+	}
+	return resp, err
+}
+
+func (s frameworkServer) PrepareProviderConfig(ctx context.Context, req *tfprotov5.PrepareProviderConfigRequest) (*tfprotov5.PrepareProviderConfigResponse, error) {
+	return &tfprotov5.PrepareProviderConfigResponse{}, nil
+}
+
+func (s frameworkServer) ConfigureProvider(ctx context.Context, req *tfprotov5.ConfigureProviderRequest) (*tfprotov5.ConfigureProviderResponse, error) {
+	empty := tftypes.Object{AttributeTypes: map[string]tftypes.Type{}}
+
+	config, err := tfprotov5.NewDynamicValue(empty, tftypes.NewValue(empty, map[string]tftypes.Value{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ProviderServer.ConfigureProvider(ctx, &tfprotov5.ConfigureProviderRequest{
+		TerraformVersion:   req.TerraformVersion,
+		Config:             &config,
+		ClientCapabilities: req.ClientCapabilities,
+	})
+}
+
+// frameworkProvider is the terraform-plugin-framework half of the provider.
+type frameworkProvider struct {
+	sdk     *schema.Provider
+	version string
+}
+
+var _ fwprovider.ProviderWithEphemeralResources = &frameworkProvider{}
+
+func (p *frameworkProvider) Metadata(ctx context.Context, req fwprovider.MetadataRequest, resp *fwprovider.MetadataResponse) {
+	resp.TypeName = "pas"
+	resp.Version = p.version
+}
+
+func (p *frameworkProvider) Schema(ctx context.Context, req fwprovider.SchemaRequest, resp *fwprovider.SchemaResponse) {
+	resp.Schema = fwschema.Schema{}
+}
+
+func (p *frameworkProvider) Configure(ctx context.Context, req fwprovider.ConfigureRequest, resp *fwprovider.ConfigureResponse) {
+	client, ok := p.sdk.Meta().(*apiClient)
+	if !ok {
+		resp.Diagnostics.AddError("Provider not configured", "The CyberArk PAS client was not configured before it was needed. This is a bug in the provider.")
+		return
+	}
+
+	resp.EphemeralResourceData = client
+}
+
+func (p *frameworkProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+func (p *frameworkProvider) Resources(ctx context.Context) []func() resource.Resource {
+	return nil
+}
+
+func (p *frameworkProvider) EphemeralResources(ctx context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		newEphemeralAccountSecret,
+	}
+}
+
+// appendDiags appends SDK diagnostics to framework diagnostics, so that
+// helpers shared with the SDK resources can be used by the framework ones.
+func appendDiags(dst *fwdiag.Diagnostics, diags diag.Diagnostics) {
+	for _, d := range diags {
+		if d.Severity == diag.Warning {
+			dst.AddWarning(d.Summary, d.Detail)
+		} else {
+			dst.AddError(d.Summary, d.Detail)
+		}
+	}
+}
@@ -1,22 +1,105 @@
 package provider
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/umich-vci/gopas"
 )
 
-func returnResponseErr(resp *http.Response, err error) diag.Diagnostics {
-	var diags diag.Diagnostics
-	diags = append(diags, diag.FromErr(err)...)
+// suppressEqualFold suppresses diffs between values that only differ in case.
+func suppressEqualFold(k, old, new string, d *schema.ResourceData) bool {
+	return strings.EqualFold(old, new)
+}
+
+// apiRequest sends a request to a PVWA endpoint that gopas does not model, or
+// models incompletely. It reuses the gopas configuration so the host, default
+// headers and HTTP client match the generated calls. As with gopas, responses
+// with a status of 300 or above are returned with an error and a readable body.
+func apiRequest(ctx context.Context, client gopas.APIClient, method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	cfg := client.GetConfig()
+
+	u, err := apiURL(ctx, client, path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	for k, v := range cfg.DefaultHeader {
+		req.Header.Add(k, v)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
 
 	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewBuffer(b))
 	if err != nil {
-		diags = append(diags, diag.FromErr(err)...)
+		return resp, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return resp, errors.New(resp.Status)
+	}
+
+	if out != nil && len(b) > 0 {
+		if err := json.Unmarshal(b, out); err != nil {
+			return resp, err
+		}
 	}
 
-	diags = append(diags, diag.Errorf(string(b))...)
-	return diags
+	return resp, nil
+}
+
+// apiURL returns the URL of a PVWA endpoint, built the same way as the URLs of
+// the generated gopas calls.
+func apiURL(ctx context.Context, client gopas.APIClient, path string) (*url.URL, error) {
+	cfg := client.GetConfig()
+
+	base, err := cfg.ServerURLWithContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(base + path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Host != "" {
+		u.Host = cfg.Host
+	}
+	if cfg.Scheme != "" {
+		u.Scheme = cfg.Scheme
+	}
 
+	return u, nil
 }
@@ -3,7 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
-	"io"
+	"net/http"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -34,16 +34,16 @@ func New(version string) func() *schema.Provider {
 			Schema: map[string]*schema.Schema{
 				"username": {
 					Type:        schema.TypeString,
-					Required:    true,
+					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("PAS_USERNAME", nil),
-					Description: "This is the username to use to access the CyberArk PAS server. This must be provided in the config or in the environment variable `PAS_USERNAME`.",
+					Description: "This is the username to use to access the CyberArk PAS server. This must be provided in the config or in the environment variable `PAS_USERNAME` when using the `logon` authentication method.",
 				},
 				"password": {
 					Type:        schema.TypeString,
-					Required:    true,
+					Optional:    true,
 					Sensitive:   true,
 					DefaultFunc: schema.EnvDefaultFunc("PAS_PASSWORD", nil),
-					Description: "This is the password to use to access the CyberArk PAS server. This must be provided in the config or in the environment variable `PAS_PASSWORD`.",
+					Description: "This is the password to use to access the CyberArk PAS server. This must be provided in the config or in the environment variable `PAS_PASSWORD` when using the `logon` authentication method.",
 				},
 				"pas_host": {
 					Type:        schema.TypeString,
@@ -53,17 +53,244 @@ func New(version string) func() *schema.Provider {
 				},
 				"auth_type": {
 					Type:         schema.TypeString,
-					Required:     true,
+					Optional:     true,
 					DefaultFunc:  schema.EnvDefaultFunc("PAS_AUTH_TYPE", nil),
-					ValidateFunc: validation.StringInSlice([]string{"ldap", "radius", "cyberark"}, true),
-					Description:  "This is the authentication type to use with the CyberArk PAS server. This must be provided in the config or in the environment variable `PAS_AUTH_TYPE`.",
+					ValidateFunc: validation.StringInSlice([]string{"ldap", "radius", "cyberark", authTypePKI, authTypeSAML, authTypeWindows}, true),
+					Description:  "This is the authentication type to use with the CyberArk PAS server. This must be provided in the config or in the environment variable `PAS_AUTH_TYPE` when using the `logon` authentication method. `pki` logs on with the client certificate, and `saml` and `windows` log on with `assertion` or `assertion_command`, instead of `username` and `password`.",
+				},
+				"credential_source": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "This fetches the password, and optionally the username, used by the `logon` authentication method instead of taking them from `password` and `username`. The credentials are fetched again every time the provider logs on. A `username` set in the config or environment takes precedence over one fetched.",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"type": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringInSlice([]string{credentialSourceCCP, credentialSourcePasswordSDK, credentialSourceFile, credentialSourceCommand}, false),
+								Description:  "This is where the credentials are fetched from. `ccp` calls the GetPassword web service of a Central Credential Provider. `password_sdk` runs the CLI Password SDK of a local Credential Provider. `file` reads the password from a file. `command` runs a command that prints a JSON object with `Username` and `Password` keys.",
+							},
+							"app_id": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "This is the application ID used by the `ccp` and `password_sdk` sources.",
+							},
+							"query": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "This is the query that finds the account used by the `ccp` and `password_sdk` sources, such as `Safe=Terraform;Object=pvwa-terraform`.",
+							},
+							"ccp_url": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+								Description:  "This is the URL of the Central Credential Provider used by the `ccp` source, such as `https://ccp.example.com`. The client certificate of the provider, if any, is presented to it.",
+							},
+							"sdk_path": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Default:     defaultPasswordSDKPath,
+								Description: "This is the path of the CLI Password SDK used by the `password_sdk` source.",
+							},
+							"path": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "This is the path of the file that the `file` source reads the password from.",
+							},
+							"command": {
+								Type:        schema.TypeList,
+								Optional:    true,
+								Description: "This is the command run by the `command` source, given as the program followed by its arguments.",
+								Elem: &schema.Schema{
+									Type:         schema.TypeString,
+									ValidateFunc: validation.StringIsNotEmpty,
+								},
+							},
+						},
+					},
+				},
+				"radius_otp": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("PAS_RADIUS_OTP", nil),
+					Description: "This is the one-time password sent when the RADIUS server answers a `radius` logon with a challenge. It can also be provided in the environment variable `PAS_RADIUS_OTP`. Conflicts with `otp_command`.",
+				},
+				"otp_command": {
+					Type:          schema.TypeList,
+					Optional:      true,
+					ConflictsWith: []string{"radius_otp"},
+					Description:   "This is a command, given as the program followed by its arguments, that prints the one-time password sent when the RADIUS server answers a `radius` logon with a challenge. The challenge is passed in the environment variable `PAS_RADIUS_CHALLENGE`. It is run for every challenge, including when the provider logs on again after the session expires.",
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringIsNotEmpty,
+					},
+				},
+				"assertion": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("PAS_ASSERTION", nil),
+					Description: "This is the assertion exchanged for a session by the `saml` and `windows` authentication types: the base64 encoded SAML response from the identity provider, or a base64 encoded Kerberos SPNEGO token for the PVWA. It can also be provided in the environment variable `PAS_ASSERTION`. Conflicts with `assertion_command`.",
+				},
+				"assertion_command": {
+					Type:          schema.TypeList,
+					Optional:      true,
+					ConflictsWith: []string{"assertion"},
+					Description:   "This is a command, given as the program followed by its arguments, that prints the assertion used by the `saml` and `windows` authentication types. It is run every time the provider logs on.",
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringIsNotEmpty,
+					},
+				},
+				"base_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Default:     "/PasswordVault",
+					Description: "This is the path of the PVWA application on the CyberArk PAS server, for a PVWA installed in a virtual directory other than the default.",
+				},
+				"ca_cert_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("PAS_CA_CERT_FILE", nil),
+					Description: "This is the path to a file of PEM encoded certificate authorities trusted to sign the certificate of the CyberArk PAS server, in addition to the system ones. It can also be provided in the environment variable `PAS_CA_CERT_FILE`. Conflicts with `ca_cert_pem`.",
+				},
+				"ca_cert_pem": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"ca_cert_file"},
+					Description:   "These are PEM encoded certificate authorities trusted to sign the certificate of the CyberArk PAS server, in addition to the system ones. Conflicts with `ca_cert_file`.",
+				},
+				"insecure_skip_verify": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("PAS_INSECURE_SKIP_VERIFY", false),
+					Description: "This disables the verification of the certificate of the CyberArk PAS server, which allows anyone on the network path to read the credentials and secrets sent by the provider. Only use it for testing. It can also be provided in the environment variable `PAS_INSECURE_SKIP_VERIFY`.",
+				},
+				"proxy_url": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "socks5"}),
+					Description:  "This is the URL of the proxy used to reach the CyberArk PAS server. If not set, the proxy is taken from the `HTTPS_PROXY` and `NO_PROXY` environment variables.",
+				},
+				"connect_timeout": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      30,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "This is how long to wait, in seconds, for a connection to the CyberArk PAS server, including the TLS handshake.",
 				},
+				"request_timeout": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      300,
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "This is how long to wait, in seconds, for a request to the CyberArk PAS server to complete. `0` means no timeout.",
+				},
+				"max_retries": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      3,
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "This is how many times a request that failed with a network error or a `429`, `502`, `503` or `504` status is sent again. Only requests that are safe to repeat are retried: reads, updates with `PUT`, deletes, and creates of safes and safe members.",
+				},
+				"retry_min_backoff": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      1,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "This is how long to wait, in seconds, before the first retry. The wait doubles with every retry, unless the CyberArk PAS server sets `Retry-After`.",
+				},
+				"retry_max_backoff": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      30,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "This is the longest wait, in seconds, between retries. A longer `Retry-After` set by the CyberArk PAS server is cut to this wait.",
+				},
+				"client_cert_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("PAS_CLIENT_CERT_FILE", nil),
+					Description: "This is the path to a PEM encoded client certificate presented to the CyberArk PAS server. It can also be provided in the environment variable `PAS_CLIENT_CERT_FILE`. Conflicts with `client_cert_pem`.",
+				},
+				"client_cert_pem": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"client_cert_file"},
+					Description:   "This is a PEM encoded client certificate presented to the CyberArk PAS server. Conflicts with `client_cert_file`.",
+				},
+				"client_key_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("PAS_CLIENT_KEY_FILE", nil),
+					Description: "This is the path to the PEM encoded private key of the client certificate. It can also be provided in the environment variable `PAS_CLIENT_KEY_FILE`. Conflicts with `client_key_pem`.",
+				},
+				"client_key_pem": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					Sensitive:     true,
+					ConflictsWith: []string{"client_key_file"},
+					Description:   "This is the PEM encoded private key of the client certificate. Conflicts with `client_key_file`.",
+				},
+				"auth": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "This chooses how the provider authenticates to the CyberArk PAS server. Without this block, the provider logs on with `username`, `password` and `auth_type`.",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"method": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								Default:      authMethodLogon,
+								ValidateFunc: validation.StringInSlice([]string{authMethodLogon, authMethodOAuth2, authMethodToken}, false),
+								Description:  "This is the authentication method. `logon` logs on with `username`, `password` and `auth_type`. `oauth2` gets a token for Privileged Cloud from an Identity tenant with the client credentials grant. `token` uses a token issued outside of the provider.",
+							},
+							"identity_tenant_url": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								DefaultFunc: schema.EnvDefaultFunc("PAS_IDENTITY_TENANT_URL", nil),
+								Description: "This is the URL of the Identity tenant, such as `https://abc1234.id.cyberark.cloud`, used by the `oauth2` method. It can also be provided in the environment variable `PAS_IDENTITY_TENANT_URL`.",
+							},
+							"client_id": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								DefaultFunc: schema.EnvDefaultFunc("PAS_CLIENT_ID", nil),
+								Description: "This is the client ID of the Identity service user used by the `oauth2` method. It can also be provided in the environment variable `PAS_CLIENT_ID`.",
+							},
+							"client_secret": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Sensitive:   true,
+								DefaultFunc: schema.EnvDefaultFunc("PAS_CLIENT_SECRET", nil),
+								Description: "This is the client secret of the Identity service user used by the `oauth2` method. It can also be provided in the environment variable `PAS_CLIENT_SECRET`.",
+							},
+							"token": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Sensitive:   true,
+								DefaultFunc: schema.EnvDefaultFunc("PAS_TOKEN", nil),
+								Description: "This is the token used by the `token` method, sent as the `Authorization` header. Privileged Cloud tokens must include the `Bearer` scheme. It can also be provided in the environment variable `PAS_TOKEN`.",
+							},
+						},
+					},
+				},
+			},
+			DataSourcesMap: map[string]*schema.Resource{
+				"pas_account":        dataSourceAccount(),
+				"pas_account_secret": dataSourceAccountSecret(),
+				"pas_accounts":       dataSourceAccounts(),
 			},
-			DataSourcesMap: map[string]*schema.Resource{},
 			ResourcesMap: map[string]*schema.Resource{
+				"pas_account":                     resourceAccount(),
 				"pas_account_aws_iam_user":        resourceAccountAWSIAMUser(),
 				"pas_account_aws_access_key":      resourceAccountAWSAccessKey(),
 				"pas_account_gcp_service_account": resourceAccountGCPServiceAccount(),
+				"pas_safe":                        resourceSafe(),
+				"pas_safe_member":                 resourceSafeMember(),
+				"pas_safe_members":                resourceSafeMembers(),
 			},
 		}
 
@@ -75,41 +302,57 @@ func New(version string) func() *schema.Provider {
 
 type apiClient struct {
 	Client gopas.APIClient
+
+	session *session
 }
 
 func configure(version string, p *schema.Provider) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 		userAgent := p.UserAgent("terraform-provider-pas", version)
 
-		username := d.Get("username").(string)
-		password := d.Get("password").(string)
-		authType := d.Get("auth_type").(string)
 		host := d.Get("pas_host").(string)
-		concurrent := true
 
 		config := gopas.NewConfiguration()
 		config.UserAgent = userAgent
 		config.Host = host
+		config.Servers[0].URL = serverURL(d.Get("base_path").(string))
 
-		data := *gopas.NewLogonData()
-		data.UserName = &username
-		data.Password = &password
-		data.ConcurrentSession = &concurrent
+		var diags diag.Diagnostics
+		if d.Get("insecure_skip_verify").(bool) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "TLS certificate verification is disabled",
+				Detail:   "insecure_skip_verify is set, so the certificate of the CyberArk PAS server is not verified. Anyone on the network path can impersonate the server and read the credentials and secrets sent by the provider. Trust the certificate authority of the server with ca_cert_file or ca_cert_pem instead.",
+			})
+		}
 
-		client := gopas.NewAPIClient(config)
-		resp, err := client.AuthApi.AuthLogon(ctx, authType).Data(data).Execute()
+		httpClient, err := newHTTPClient(d)
 		if err != nil {
-			return nil, diag.FromErr(err)
+			return nil, append(diags, diag.FromErr(err)...)
 		}
 
-		token, err := io.ReadAll(resp.Body)
+		// Logons use their own client, so that they are not sent through
+		// the session they open.
+		logonConfig := *config
+		logonConfig.HTTPClient = httpClient
+		logonClient := gopas.NewAPIClient(&logonConfig)
+
+		auth, err := newAuthenticator(d)
 		if err != nil {
-			return nil, diag.FromErr(err)
+			return nil, append(diags, diag.FromErr(err)...)
+		}
+
+		s := newSession(auth, logonClient)
+		if _, err := s.open(ctx, ""); err != nil {
+			return nil, append(diags, errorDiags(err)...)
 		}
 
-		//Add the token we just got to the default header and strip away the outermost quotation marks
-		client.GetConfig().AddDefaultHeader("Authorization", string(token[1:len(token)-1]))
+		config.HTTPClient = &http.Client{Transport: s, Timeout: httpClient.Timeout}
+		client := gopas.NewAPIClient(config)
+
+		c := &apiClient{Client: *client, session: s}
+		registerSession(c)
 
-		return &apiClient{Client: *client}, nil
+		return c, diags
 	}
 }
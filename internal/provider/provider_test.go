@@ -1,18 +1,94 @@
 package provider
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/umich-vci/gopas"
+	"github.com/umich-vci/terraform-provider-pas/internal/pasfake"
 )
 
-// providerFactories are used to instantiate a provider during acceptance testing.
+// protoV5ProviderFactories are used to instantiate a provider during acceptance testing.
 // The factory function will be invoked for every Terraform CLI command executed
 // to create a provider server to which the CLI can reattach.
-var providerFactories = map[string]func() (*schema.Provider, error){
-	"scaffolding": func() (*schema.Provider, error) {
-		return New("dev")(), nil
-	},
+var protoV5ProviderFactories = map[string]func() (tfprotov5.ProviderServer, error){
+	"pas": ProviderServer("dev"),
+}
+
+var (
+	testAccProviderOnce       sync.Once
+	testAccProviderConfigured *schema.Provider
+)
+
+// testAccProvider returns a provider configured from the PAS_* environment
+// variables, for checks that need to call the API directly.
+func testAccProvider() *schema.Provider {
+	testAccProviderOnce.Do(func() {
+		p := New("dev")()
+		diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(nil))
+		if diags.HasError() {
+			panic(diags[0].Summary)
+		}
+		testAccProviderConfigured = p
+	})
+
+	return testAccProviderConfigured
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+// testMain runs the tests. Acceptance tests run against a CyberArk PAS server
+// when PAS_HOST is set, and against an in-memory fake of the PVWA otherwise.
+func testMain(m *testing.M) int {
+	if os.Getenv(resource.EnvTfAcc) == "" || os.Getenv("PAS_HOST") != "" {
+		return m.Run()
+	}
+
+	server := pasfake.NewServer("terraform", "Terraform-Passw0rd")
+	defer server.Close()
+
+	dir, err := os.MkdirTemp("", "pasfake")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, []byte(server.CACertPEM()), 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for env, value := range map[string]string{
+		"PAS_HOST":         server.Host(),
+		"PAS_USERNAME":     server.Username,
+		"PAS_PASSWORD":     server.Password,
+		"PAS_AUTH_TYPE":    "cyberark",
+		"PAS_CA_CERT_FILE": caFile,
+	} {
+		os.Setenv(env, value)
+	}
+
+	return m.Run()
 }
 
 func TestProvider(t *testing.T) {
@@ -21,8 +97,144 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+func TestProviderServer(t *testing.T) {
+	server, err := ProviderServer("dev")()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resp, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, d := range resp.Diagnostics {
+		t.Errorf("%s: %s", d.Summary, d.Detail)
+	}
+
+	if resp.Provider == nil || len(resp.Provider.Block.Attributes) == 0 {
+		t.Error("provider schema is missing")
+	}
+	if _, ok := resp.EphemeralResourceSchemas["pas_account_secret"]; !ok {
+		t.Error("ephemeral resource pas_account_secret is missing")
+	}
+	if _, ok := resp.ResourceSchemas["pas_account"]; !ok {
+		t.Error("resource pas_account is missing")
+	}
+}
+
+func TestProviderConfigure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/vault/pvwa/api/Safes" || r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	p := New("dev")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"pas_host":             u.Host,
+		"base_path":            "/vault/pvwa",
+		"insecure_skip_verify": true,
+		"auth": []interface{}{map[string]interface{}{
+			"method": "token",
+			"token":  "Bearer abc",
+		}},
+	}))
+	if diags.HasError() {
+		t.Fatalf("configure failed: %v", diags)
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("expected a warning for insecure_skip_verify, got %v", diags)
+	}
+
+	client := p.Meta().(*apiClient).Client
+	if _, err := apiRequest(context.Background(), client, http.MethodGet, "/api/Safes", nil, nil, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProviderConflictingPEMArguments(t *testing.T) {
+	for _, c := range []struct {
+		pemAttr, fileAttr string
+	}{
+		{"ca_cert_pem", "ca_cert_file"},
+		{"client_cert_pem", "client_cert_file"},
+		{"client_key_pem", "client_key_file"},
+	} {
+		diags := New("dev")().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{
+			c.pemAttr:  "-----BEGIN CERTIFICATE-----",
+			c.fileAttr: "/etc/pki/pas.pem",
+		}))
+
+		found := false
+		for _, d := range diags {
+			if strings.Contains(d.Summary+d.Detail, "conflicts with") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("setting %s and %s: expected a conflict, got %v", c.pemAttr, c.fileAttr, diags)
+		}
+	}
+}
+
 func testAccPreCheck(t *testing.T) {
-	// You can add code here to run prior to any test case execution, for example assertions
-	// about the appropriate environment variables being set are common to see in a pre-check
-	// function.
+	for _, env := range []string{"PAS_HOST", "PAS_USERNAME", "PAS_PASSWORD", "PAS_AUTH_TYPE"} {
+		if os.Getenv(env) == "" {
+			t.Skipf("%s must be set to run acceptance tests against a CyberArk PAS server", env)
+		}
+	}
+}
+
+// testAccPreCheckWriteOnly skips acceptance tests that set write-only
+// arguments when the Terraform CLI is older than 1.11, which rejects them. If
+// no Terraform CLI is found, the test framework installs the latest release.
+func testAccPreCheckWriteOnly(t *testing.T) {
+	testAccPreCheck(t)
+
+	path := os.Getenv("TF_ACC_TERRAFORM_PATH")
+	if path == "" {
+		var err error
+		if path, err = exec.LookPath("terraform"); err != nil {
+			return
+		}
+	}
+
+	out, err := exec.Command(path, "version", "-json").Output()
+	if err != nil {
+		t.Fatalf("reading the Terraform version: %s", err)
+	}
+
+	var v struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		t.Fatalf("reading the Terraform version: %s", err)
+	}
+
+	tf, err := version.NewVersion(v.TerraformVersion)
+	if err != nil {
+		t.Fatalf("reading the Terraform version: %s", err)
+	}
+	if tf.Core().LessThan(version.Must(version.NewVersion("1.11.0"))) {
+		t.Skipf("Terraform %s does not support write-only arguments", tf)
+	}
+}
+
+// testAPIClient returns an apiClient that sends its requests to handler.
+func testAPIClient(t *testing.T, handler http.HandlerFunc) *apiClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	config := gopas.NewConfiguration()
+	config.Host = u.Host
+	config.Scheme = u.Scheme
+
+	return &apiClient{Client: *gopas.NewAPIClient(config)}
 }
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/umich-vci/gopas"
+)
+
+func resourceAccount() *schema.Resource {
+	return &schema.Resource{
+		Description: "Resource to manage an account on any platform in CyberArk PAS",
+
+		CreateContext: resourceAccountCreate,
+		ReadContext:   resourceAccountRead,
+		UpdateContext: resourceAccountUpdate,
+		DeleteContext: resourceAccountDelete,
+
+		CustomizeDiff: customizeDiffSecretDrift("secret"),
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAccountImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"safe_name": {
+				Description:  "The name of the safe to create the account in.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"platform_id": {
+				Description:  "The ID of the platform to assign to the account, such as `WinServerLocal` or `UnixSSH`.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"address": {
+				Description: "The name or address of the machine where the account is used.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"username": {
+				Description: "The username of the account.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"name": {
+				Description: "The name of the account. If not specified, one is generated.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"secret": {
+				Description: "The password or key of the account. This is write-only: it is sent to the Vault when the account is created and whenever `secret_version` changes, and is never stored in the plan or state.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				WriteOnly:   true,
+			},
+			"secret_version": {
+				Description: "An arbitrary number to change when `secret` should be sent to the Vault again.",
+				Type:        schema.TypeInt,
+				Optional:    true,
+			},
+			"secret_lifecycle":          accountSecretLifecycleSchema("secret", "secret_version"),
+			"managed_secret_version_id": managedSecretVersionIDSchema(),
+			"vault_secret_version_id":   vaultSecretVersionIDSchema(),
+			"secret_type": {
+				Description:  "The type of the secret. Valid values are `password` and `key`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "password",
+				ValidateFunc: validation.StringInSlice([]string{"password", "key"}, false),
+			},
+			"platform_account_properties": {
+				Description: "Additional properties of the account, as defined by its platform.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"secret_management":      accountSecretManagementSchema(),
+			"remote_machines_access": accountRemoteMachinesAccessSchema(),
+			"category_modification_time": {
+				Description: "When the account's properties were last modified, in Unix time.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"created_time": {
+				Description: "When the account was created",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceAccountCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	platformID := d.Get("platform_id").(string)
+	safeName := d.Get("safe_name").(string)
+
+	account := *gopas.NewAccountModel(platformID, safeName)
+
+	if a, ok := d.GetOk("address"); ok {
+		address := a.(string)
+		account.Address = &address
+	}
+
+	if u, ok := d.GetOk("username"); ok {
+		username := u.(string)
+		account.UserName = &username
+	}
+
+	if n, ok := d.GetOk("name"); ok {
+		name := n.(string)
+		account.Name = &name
+	}
+
+	secret, diags := createSecret(d, "secret")
+	if diags.HasError() {
+		return diags
+	}
+	if secret != "" {
+		account.Secret = &secret
+	}
+
+	secretType := d.Get("secret_type").(string)
+	account.SecretType = &secretType
+
+	prop := make(map[string]string)
+	for k, v := range d.Get("platform_account_properties").(map[string]interface{}) {
+		prop[k] = v.(string)
+	}
+	account.PlatformAccountProperties = &prop
+
+	account.SecretManagement = expandAccountSecretManagement(d)
+	account.RemoteMachinesAccess = expandAccountRemoteMachinesAccess(d)
+
+	act, resp, err := client.AccountsApi.AccountsAddAccount(ctx).Account(account).Execute()
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	d.SetId(act["id"].(string))
+
+	if diags := recordSecretVersion(ctx, client, d); diags.HasError() {
+		return diags
+	}
+
+	return resourceAccountRead(ctx, d, meta)
+}
+
+func resourceAccountRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Id()
+
+	account, resp, err := client.AccountsApi.AccountsGetAccount(ctx, id).Execute()
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	d.Set("address", account.Address)
+	d.Set("category_modification_time", account.CategoryModificationTime)
+	d.Set("created_time", account.CreatedTime)
+	d.Set("name", account.Name)
+	d.Set("platform_account_properties", account.PlatformAccountProperties)
+	d.Set("platform_id", account.PlatformId)
+	d.Set("remote_machines_access", flattenAccountRemoteMachinesAccess(account.RemoteMachinesAccess))
+	d.Set("safe_name", account.SafeName)
+	d.Set("secret_management", flattenAccountSecretManagement(account.SecretManagement))
+	d.Set("secret_type", account.SecretType)
+	d.Set("username", account.UserName)
+
+	return readSecretVersion(ctx, client, d)
+}
+
+func resourceAccountUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Id()
+
+	operations := []accountPatchOperation{}
+
+	root := []struct{ attr, field string }{
+		{"platform_id", "platformId"},
+		{"address", "address"},
+		{"username", "userName"},
+		{"name", "name"},
+	}
+	for _, r := range root {
+		if d.HasChange(r.attr) {
+			operations = append(operations, accountPatchOperation{Op: "replace", Path: accountPatchPath(r.field), Value: d.Get(r.attr)})
+		}
+	}
+
+	if d.HasChange("platform_account_properties") {
+		o, n := d.GetChange("platform_account_properties")
+		operations = append(operations, accountPropertiesPatch(o.(map[string]interface{}), n.(map[string]interface{}))...)
+	}
+
+	operations = append(operations, accountSecretManagementPatch(d)...)
+
+	operations = append(operations, accountRemoteMachinesAccessPatch(d)...)
+
+	resp, err := updateAccount(ctx, client, id, operations)
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	if diags := updateSecret(ctx, client, d, "secret", "secret_version"); diags.HasError() {
+		return diags
+	}
+
+	return resourceAccountRead(ctx, d, meta)
+}
+
+func resourceAccountDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Id()
+
+	// A deletion retried after its response was lost finds the account gone.
+	_, resp, err := client.AccountsApi.AccountsDeleteAccount(ctx, id).Execute()
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	d.SetId("")
+
+	return nil
+}
@@ -17,6 +17,12 @@ func resourceAccountAWSIAMUser() *schema.Resource {
 		UpdateContext: resourceAccountAWSIAMUserUpdate,
 		DeleteContext: resourceAccountAWSIAMUserDelete,
 
+		CustomizeDiff: customizeDiffSecretDrift("password"),
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAccountImport,
+		},
+
 		Schema: map[string]*schema.Schema{
 			"aws_account_id": {
 				Description: "The account ID on the AWS console. This is a 12-digit number such as 123456789012.",
@@ -27,6 +33,7 @@ func resourceAccountAWSIAMUser() *schema.Resource {
 				Description: "The name of the safe to create the AWS IAM account in.",
 				Type:        schema.TypeString,
 				Required:    true,
+				ForceNew:    true,
 			},
 			"username": {
 				Description: "The username of the IAM user. This is required for reconcile actions.",
@@ -40,11 +47,20 @@ func resourceAccountAWSIAMUser() *schema.Resource {
 				Default:     "www.AWS.com",
 			},
 			"password": {
-				Description: "The password of the IAM user.",
+				Description: "The password of the IAM user. This is write-only: it is sent to the Vault when the account is created and whenever `password_version` changes, and is never stored in the plan or state.",
 				Type:        schema.TypeString,
 				Optional:    true,
 				Sensitive:   true,
+				WriteOnly:   true,
+			},
+			"password_version": {
+				Description: "An arbitrary number to change when `password` should be sent to the Vault again.",
+				Type:        schema.TypeInt,
+				Optional:    true,
 			},
+			"secret_lifecycle":          accountSecretLifecycleSchema("password", "password_version"),
+			"managed_secret_version_id": managedSecretVersionIDSchema(),
+			"vault_secret_version_id":   vaultSecretVersionIDSchema(),
 			"aws_arn_role": {
 				Description: "The role that can securely access the AWS console.",
 				Type:        schema.TypeString,
@@ -66,8 +82,9 @@ func resourceAccountAWSIAMUser() *schema.Resource {
 				Optional:    true,
 				Computed:    true,
 			},
+			"secret_management": accountSecretManagementSchema(),
 			"category_modification_time": {
-				Description: "TODO",
+				Description: "When the account's properties were last modified, in Unix time.",
 				Type:        schema.TypeInt,
 				Computed:    true,
 			},
@@ -99,8 +116,11 @@ func resourceAccountAWSIAMUserCreate(ctx context.Context, d *schema.ResourceData
 		account.Name = &name
 	}
 
-	if p, ok := d.GetOk("password"); ok {
-		password := p.(string)
+	password, diags := createSecret(d, "password")
+	if diags.HasError() {
+		return diags
+	}
+	if password != "" {
 		account.Secret = &password
 	}
 
@@ -118,13 +138,19 @@ func resourceAccountAWSIAMUserCreate(ctx context.Context, d *schema.ResourceData
 	}
 	account.PlatformAccountProperties = &prop
 
+	account.SecretManagement = expandAccountSecretManagement(d)
+
 	act, resp, err := client.AccountsApi.AccountsAddAccount(ctx).Account(account).Execute()
 	if err != nil {
-		return returnResponseErr(resp, err)
+		return apiErrorDiags(resp, err)
 	}
 
 	d.SetId(act["id"].(string))
 
+	if diags := recordSecretVersion(ctx, client, d); diags.HasError() {
+		return diags
+	}
+
 	return resourceAccountAWSIAMUserRead(ctx, d, meta)
 }
 
@@ -135,27 +161,38 @@ func resourceAccountAWSIAMUserRead(ctx context.Context, d *schema.ResourceData,
 
 	account, resp, err := client.AccountsApi.AccountsGetAccount(ctx, id).Execute()
 	if err != nil {
-		if resp.StatusCode == 404 {
+		if isNotFound(resp) {
 			d.SetId("")
 			return nil
 		}
 
-		return returnResponseErr(resp, err)
+		return apiErrorDiags(resp, err)
 	}
 
+	prop := account.GetPlatformAccountProperties()
+
 	d.Set("address", account.Address)
-	d.Set("aws_account_alias_name", (*account.PlatformAccountProperties)["AWSAccountAliasName"])
-	d.Set("aws_account_id", (*account.PlatformAccountProperties)["AWSAccountID"])
-	d.Set("aws_arn_role", (*account.PlatformAccountProperties)["AWSARNRole"])
-	d.Set("aws_policy", (*account.PlatformAccountProperties)["AWSPolicy"])
+	d.Set("aws_account_alias_name", prop["AWSAccountAliasName"])
+	d.Set("aws_account_id", prop["AWSAccountID"])
+	d.Set("aws_arn_role", prop["AWSARNRole"])
+	d.Set("aws_policy", prop["AWSPolicy"])
 	d.Set("category_modification_time", account.CategoryModificationTime)
 	d.Set("created_time", account.CreatedTime)
 	d.Set("name", account.Name)
-	d.Set("password", account.Secret)
 	d.Set("safe_name", account.SafeName)
+	d.Set("secret_management", flattenAccountSecretManagement(account.SecretManagement))
 	d.Set("username", account.UserName)
 
-	return nil
+	return readSecretVersion(ctx, client, d)
+}
+
+// awsIAMUserProperties maps the attributes of pas_account_aws_iam_user to the
+// platform account properties they are stored in.
+var awsIAMUserProperties = map[string]string{
+	"aws_account_id":         "AWSAccountID",
+	"aws_arn_role":           "AWSARNRole",
+	"aws_policy":             "AWSPolicy",
+	"aws_account_alias_name": "AWSAccountAliasName",
 }
 
 func resourceAccountAWSIAMUserUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
@@ -163,73 +200,30 @@ func resourceAccountAWSIAMUserUpdate(ctx context.Context, d *schema.ResourceData
 
 	id := d.Id()
 
-	operations := []gopas.OperationAccountModel{}
-	op := "replace"
-
-	rootProp := make(map[string]interface{})
-
-	if d.HasChange("safe_name") {
-		rootProp["safeName"] = d.Get("safe_name")
-	}
-
-	if d.HasChange("name") {
-		rootProp["name"] = d.Get("name")
-	}
-
-	if d.HasChange("username") {
-		rootProp["userName"] = d.Get("name")
-	}
-
-	if d.HasChange("password") {
-		rootProp["secret"] = d.Get("password")
-	}
-
-	if d.HasChange("address") {
-		rootProp["address"] = d.Get("address")
-	}
+	operations := []accountPatchOperation{}
 
-	if len(rootProp) > 0 {
-		path := "/"
-		operation := *gopas.NewOperationAccountModel()
-		operation.Op = &op
-		operation.Path = &path
-		operation.Value = &rootProp
-		operations = append(operations, operation)
+	root := []struct{ attr, field string }{
+		{"address", "address"},
+		{"username", "userName"},
+		{"name", "name"},
 	}
-
-	prop := make(map[string]interface{})
-
-	if d.HasChange("aws_account_id") {
-		prop["AWSAccountID"] = d.Get("aws_account_id")
-	}
-
-	if d.HasChange("aws_arn_role") {
-		prop["AWSARNRole"] = d.Get("aws_arn_role")
+	for _, r := range root {
+		if d.HasChange(r.attr) {
+			operations = append(operations, accountPatchOperation{Op: "replace", Path: accountPatchPath(r.field), Value: d.Get(r.attr)})
+		}
 	}
 
-	if d.HasChange("aws_policy") {
-		prop["AWSPolicy"] = d.Get("aws_policy")
-	}
+	o, n := accountPropertiesChange(d, awsIAMUserProperties)
+	operations = append(operations, accountPropertiesPatch(o, n)...)
 
-	if d.HasChange("aws_account_alias_name") {
-		prop["AWSAccountAliasName"] = d.Get("aws_account_alias_name")
-	}
+	operations = append(operations, accountSecretManagementPatch(d)...)
 
-	if len(prop) > 0 {
-		path := "/platformAccountProperties"
-		operation := *gopas.NewOperationAccountModel()
-		operation.Op = &op
-		operation.Path = &path
-		operation.Value = &prop
-		operations = append(operations, operation)
+	if resp, err := updateAccount(ctx, client, id, operations); err != nil {
+		return apiErrorDiags(resp, err)
 	}
 
-	patch := *gopas.NewJsonPatchDocumentAccountModel()
-	patch.SetOperations(operations)
-
-	_, resp, err := client.AccountsApi.AccountsUpdateAccount(ctx, id).AccountPatch(patch).Execute()
-	if err != nil {
-		return returnResponseErr(resp, err)
+	if diags := updateSecret(ctx, client, d, "password", "password_version"); diags.HasError() {
+		return diags
 	}
 
 	return resourceAccountAWSIAMUserRead(ctx, d, meta)
@@ -240,9 +234,15 @@ func resourceAccountAWSIAMUserDelete(ctx context.Context, d *schema.ResourceData
 
 	id := d.Id()
 
+	// A deletion retried after its response was lost finds the account gone.
 	_, resp, err := client.AccountsApi.AccountsDeleteAccount(ctx, id).Execute()
 	if err != nil {
-		return returnResponseErr(resp, err)
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
 	}
 
 	d.SetId("")
@@ -1,32 +1,101 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"regexp"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 )
 
 func TestAccResourceAccountAWSIAMUser(t *testing.T) {
-	t.Skip("resource not yet implemented, remove this once you add your own code")
+	safeName := "tf" + acctest.RandString(10)
 
-	resource.UnitTest(t, resource.TestCase{
-		PreCheck:          func() { testAccPreCheck(t) },
-		ProviderFactories: providerFactories,
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		CheckDestroy:             testAccCheckAccountDestroy("pas_account_aws_iam_user"),
 		Steps: []resource.TestStep{
 			{
-				Config: testAccResourceAccountAWSIAMUser,
+				Config: testAccResourceAccountAWSIAMUser(safeName, "iamuser", `aws_arn_role = "arn:aws:iam::123456789012:role/Admin"`),
 				Check: resource.ComposeTestCheckFunc(
-					resource.TestMatchResourceAttr(
-						"scaffolding_resource.foo", "sample_attribute", regexp.MustCompile("^ba")),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "safe_name", safeName),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "username", "iamuser"),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "aws_account_id", "123456789012"),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "aws_arn_role", "arn:aws:iam::123456789012:role/Admin"),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "address", "www.AWS.com"),
+					resource.TestCheckResourceAttrSet("pas_account_aws_iam_user.test", "name"),
+					resource.TestCheckResourceAttrSet("pas_account_aws_iam_user.test", "created_time"),
 				),
 			},
+			{
+				Config: testAccResourceAccountAWSIAMUser(safeName, "iamuser2", `
+  aws_account_alias_name = "example"
+
+  secret_management {
+    automatic_management_enabled = false
+    manual_management_reason     = "Managed by Terraform tests"
+  }`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "username", "iamuser2"),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "aws_arn_role", ""),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "aws_account_alias_name", "example"),
+					resource.TestCheckResourceAttr("pas_account_aws_iam_user.test", "secret_management.0.automatic_management_enabled", "false"),
+				),
+			},
+			{
+				ResourceName:      "pas_account_aws_iam_user.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:      "pas_account_aws_iam_user.test",
+				ImportState:       true,
+				ImportStateIdFunc: testAccAccountImportStateIDFunc("pas_account_aws_iam_user.test"),
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:  "pas_account_aws_iam_user.test",
+				ImportState:   true,
+				ImportStateId: safeName + "/missing",
+				ExpectError:   regexp.MustCompile(`no account named "missing" was found in safe`),
+			},
 		},
 	})
 }
 
-const testAccResourceAccountAWSIAMUser = `
-resource "scaffolding_resource" "foo" {
-  sample_attribute = "bar"
+func TestResourceAccountAWSIAMUserReadWithoutProperties(t *testing.T) {
+	client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1_1","safeName":"AWS","platformId":"UnixSSH"}`)
+	})
+
+	d := resourceAccountAWSIAMUser().TestResourceData()
+	d.SetId("1_1")
+
+	if diags := resourceAccountAWSIAMUserRead(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+	if d.Get("aws_account_id") != "" || d.Get("username") != "" || d.Get("safe_name") != "AWS" {
+		t.Errorf("unexpected state %v", d.State())
+	}
+}
+
+func testAccResourceAccountAWSIAMUser(safeName, username, extra string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_account_aws_iam_user" "test" {
+  safe_name      = pas_safe.test.safe_name
+  username       = %[2]q
+  aws_account_id = "123456789012"
+  %[3]s
+}
+`, safeName, username, extra)
 }
-`
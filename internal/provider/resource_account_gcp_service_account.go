@@ -2,8 +2,14 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/umich-vci/gopas"
@@ -18,8 +24,13 @@ func resourceAccountGCPServiceAccount() *schema.Resource {
 		UpdateContext: resourceAccountGCPServiceAccountUpdate,
 		DeleteContext: resourceAccountGCPServiceAccountDelete,
 
+		CustomizeDiff: customdiff.All(
+			customizeDiffSecretDrift("key_json"),
+			customizeDiffGCPServiceAccountKey,
+		),
+
 		Importer: &schema.ResourceImporter{
-			StateContext: schema.ImportStatePassthroughContext,
+			StateContext: resourceAccountImport,
 		},
 
 		Schema: map[string]*schema.Schema{
@@ -27,6 +38,7 @@ func resourceAccountGCPServiceAccount() *schema.Resource {
 				Description:  "The name of the safe to create the GCP service account in.",
 				Type:         schema.TypeString,
 				Required:     true,
+				ForceNew:     true,
 				ValidateFunc: validation.StringIsNotEmpty,
 			},
 			"username": {
@@ -115,19 +127,37 @@ func resourceAccountGCPServiceAccount() *schema.Resource {
 				Optional: true,
 				MaxItems: 1,
 			},
+			"secret_management": accountSecretManagementSchema(),
 
 			"category_modification_time": {
-				Description: "",
+				Description: "When the account's properties were last modified, in Unix time.",
 				Type:        schema.TypeInt,
 				Computed:    true,
 			},
 			"created_time": {
-				Description: "",
+				Description: "When the account was created",
 				Type:        schema.TypeInt,
 				Computed:    true,
 			},
+			"key_json": {
+				Description: "The JSON key file of the GCP service account, as downloaded from the Google Cloud console or created by `google_service_account_key`. Its `client_email` must be `username`. " +
+					"This is write-only: it is sent to the Vault when the account is created and whenever `key_json_version` changes, and is never stored in the plan or state.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				WriteOnly:    true,
+				ValidateFunc: validateGCPServiceAccountKey,
+			},
+			"key_json_version": {
+				Description: "An arbitrary number to change when `key_json` should be sent to the Vault again.",
+				Type:        schema.TypeInt,
+				Optional:    true,
+			},
+			"secret_lifecycle":          accountSecretLifecycleSchema("key_json", "key_json_version"),
+			"managed_secret_version_id": managedSecretVersionIDSchema(),
+			"vault_secret_version_id":   vaultSecretVersionIDSchema(),
 			"key_id": {
-				Description: "The ID of the GCP key",
+				Description: "The ID of the GCP key, from the `private_key_id` of `key_json`.",
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
@@ -152,15 +182,22 @@ func resourceAccountGCPServiceAccountCreate(ctx context.Context, d *schema.Resou
 	}
 
 	prop := make(map[string]string)
-	prop["KeyID"] = "TerraformCreatedAccount"
 
 	if i, ok := d.GetOk("impersonate_user"); ok {
 		prop["ImpersonateUser"] = i.(string)
 	}
 
-	if k, ok := d.GetOk("key"); ok {
-		secret := k.(string)
-		account.Secret = &secret
+	keyJSON, diags := createSecret(d, "key_json")
+	if diags.HasError() {
+		return diags
+	}
+	if keyJSON != "" {
+		key, diags := expandGCPServiceAccountKey(keyJSON, username)
+		if diags.HasError() {
+			return diags
+		}
+		prop["KeyID"] = key.PrivateKeyID
+		account.Secret = &keyJSON
 	}
 
 	if p, ok := d.GetOk("populate_key"); ok {
@@ -173,39 +210,31 @@ func resourceAccountGCPServiceAccountCreate(ctx context.Context, d *schema.Resou
 
 	account.PlatformAccountProperties = &prop
 
+	account.SecretManagement = expandAccountSecretManagement(d)
+
 	act, resp, err := client.AccountsApi.AccountsAddAccount(ctx).Account(account).Execute()
 	if err != nil {
-		return returnResponseErr(resp, err)
+		return apiErrorDiags(resp, err)
 	}
 
 	id := act["id"].(string)
 	d.SetId(id)
 
-	if c, ok := d.GetOk("change_account"); ok {
-		changeAccount := c.(*schema.Set).List()[0].(map[string]interface{})
-		safeName := changeAccount["safe_name"].(string)
-		name := changeAccount["name"].(string)
-		folder := changeAccount["folder"].(string)
-
-		linkAccount := *gopas.NewLinkAccountData(name, safeName, folder, 2)
+	if diags := recordSecretVersion(ctx, client, d); diags.HasError() {
+		return diags
+	}
 
-		resp, err := client.AccountsApi.AccountsLinkAccount(ctx, id).LinkAccount(linkAccount).Execute()
+	if c, ok := d.GetOk("change_account"); ok {
+		resp, err := linkAccount(ctx, client, id, c.([]interface{})[0].(map[string]interface{}), 2)
 		if err != nil {
-			return returnResponseErr(resp, err)
+			return apiErrorDiags(resp, err)
 		}
 	}
 
 	if c, ok := d.GetOk("reconcile_account"); ok {
-		reconcileAccount := c.(*schema.Set).List()[0].(map[string]interface{})
-		safeName := reconcileAccount["safe_name"].(string)
-		name := reconcileAccount["name"].(string)
-		folder := reconcileAccount["folder"].(string)
-
-		linkAccount := *gopas.NewLinkAccountData(name, safeName, folder, 3)
-
-		resp, err := client.AccountsApi.AccountsLinkAccount(ctx, id).LinkAccount(linkAccount).Execute()
+		resp, err := linkAccount(ctx, client, id, c.([]interface{})[0].(map[string]interface{}), 3)
 		if err != nil {
-			return returnResponseErr(resp, err)
+			return apiErrorDiags(resp, err)
 		}
 	}
 
@@ -219,75 +248,42 @@ func resourceAccountGCPServiceAccountRead(ctx context.Context, d *schema.Resourc
 
 	account, resp, err := client.AccountsApi.AccountsGetAccount(ctx, id).Execute()
 	if err != nil {
-		if resp.StatusCode == 404 {
+		if isNotFound(resp) {
 			d.SetId("")
 			return nil
 		}
-		return returnResponseErr(resp, err)
+		return apiErrorDiags(resp, err)
 	}
 
-	accountv1, resp, err := client.AccountsApi.AccountsGetAccountLegacy(ctx).Keywords(*account.UserName).Safe(account.SafeName).Execute()
-	if err != nil {
-		var diags diag.Diagnostics
-		diags = append(diags, diag.Errorf("Error finding account name %s with legacy API", *account.Name)...)
-		diags = append(diags, returnResponseErr(resp, err)...)
+	links, diags := readLinkedAccounts(ctx, client, account)
+	if diags.HasError() {
 		return diags
 	}
 
-	if *accountv1.Count == 0 {
-		return diag.Errorf("No accounts found with legacy API")
-	}
-
-	changeAccount := make(map[string]interface{})
-	reconcileAccount := make(map[string]interface{})
+	d.Set("change_account", flattenLinkedAccount(links, 2))
+	d.Set("reconcile_account", flattenLinkedAccount(links, 3))
 
-	for _, kv := range *(*accountv1.Accounts)[0].InternalProperties {
-		switch *kv.Key {
-		case "ExtraPass2Name":
-			changeAccount["name"] = *kv.Value
-		case "ExtraPass2Folder":
-			changeAccount["folder"] = *kv.Value
-		case "ExtraPass2Safe":
-			changeAccount["safe_name"] = *kv.Value
-		case "ExtraPass3Name":
-			reconcileAccount["name"] = *kv.Value
-		case "ExtraPass3Folder":
-			reconcileAccount["folder"] = *kv.Value
-		case "ExtraPass3Safe":
-			reconcileAccount["safe_name"] = *kv.Value
-		}
-	}
+	prop := account.GetPlatformAccountProperties()
 
-	if len(changeAccount) > 0 {
-		d.Set("change_account", []map[string]interface{}{changeAccount})
-	} else {
-		d.Set("change_account", nil)
-	}
-
-	if len(reconcileAccount) > 0 {
-		d.Set("reconcile_account", []map[string]interface{}{reconcileAccount})
-	} else {
-		d.Set("reconcile_account", nil)
-	}
-
-	if (*account.PlatformAccountProperties)["PopulateKey"] == "Yes" {
+	if prop["PopulateKey"] == "Yes" {
 		d.Set("populate_key", true)
-	} else if (*account.PlatformAccountProperties)["PopulateKey"] == "No" {
+	} else if prop["PopulateKey"] == "No" {
 		d.Set("populate_key", false)
 	} else {
 		d.Set("populate_key", nil)
 	}
 
-	d.Set("key_id", (*account.PlatformAccountProperties)["KeyID"])
-	d.Set("impersonate_user", (*account.PlatformAccountProperties)["ImpersonateUser"])
+	d.Set("key_id", prop["KeyID"])
+	d.Set("impersonate_user", prop["ImpersonateUser"])
 	d.Set("safe_name", account.SafeName)
+	d.Set("secret_management", flattenAccountSecretManagement(account.SecretManagement))
 	d.Set("username", account.UserName)
 	d.Set("name", account.Name)
 	d.Set("category_modification_time", account.CategoryModificationTime)
 	d.Set("created_time", account.CreatedTime)
 	d.Set("platform_id", account.PlatformId)
 
-	return nil
+	return readSecretVersion(ctx, client, d)
 }
 
 func resourceAccountGCPServiceAccountUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
@@ -295,138 +291,71 @@ func resourceAccountGCPServiceAccountUpdate(ctx context.Context, d *schema.Resou
 
 	id := d.Id()
 
-	operations := []gopas.OperationAccountModel{}
-	opReplace := "replace"
-	opRemove := "remove"
-	opAdd := "add"
-
-	rootProp := make(map[string]interface{})
-
-	if d.HasChange("safe_name") {
-		n := d.Get("safe_name")
-		rootProp["safeName"] = n
-	}
-
-	if d.HasChange("name") {
-		n := d.Get("name")
-		rootProp["name"] = n
-	}
-
-	if d.HasChange("username") {
-		n := d.Get("username")
-		rootProp["userName"] = n
-	}
-
-	if len(rootProp) > 0 {
-		path := "/"
-		operation := *gopas.NewOperationAccountModel()
-		operation.Op = &opReplace
-		operation.Path = &path
-		operation.Value = &rootProp
-		operations = append(operations, operation)
-	}
-
-	prop := make(map[string]interface{})
-	path := "/platformAccountProperties"
-
-	if d.HasChange("impersonate_user") {
-		o, n := d.GetChange("impersonate_user")
-		if o == nil {
-			operation := gopas.OperationAccountModel{
-				Op:    &opAdd,
-				Path:  &path,
-				Value: &map[string]interface{}{"ImpersonateUser": n},
-			}
-			operations = append(operations, operation)
-		} else if n == nil {
-			operation := gopas.OperationAccountModel{
-				Op:    &opRemove,
-				Path:  &path,
-				Value: &map[string]interface{}{"ImpersonateUser": ""},
-			}
-			operations = append(operations, operation)
-		} else {
-			prop["ImpersonateUser"] = n
+	// key_json is checked before anything is changed, so that a missing or
+	// wrong key cannot leave the account with a new KeyID and the old key.
+	var key *gcpServiceAccountKey
+	if d.HasChange("key_json_version") && d.Get("secret_lifecycle").(string) != secretLifecycleIgnore {
+		keyJSON, diags := writeOnlyString(d, "key_json")
+		if diags.HasError() {
+			return diags
+		}
+		if keyJSON == "" {
+			return diag.Errorf("key_json must be set when key_json_version changes")
+		}
+		key, diags = expandGCPServiceAccountKey(keyJSON, d.Get("username").(string))
+		if diags.HasError() {
+			return diags
 		}
-
 	}
 
-	if d.HasChange("populate_key") {
-		n := d.Get("populate_key")
-		populate := "No"
-		if n == nil {
-			populate = ""
-		} else if n.(bool) {
-			populate = "Yes"
+	operations := []accountPatchOperation{}
+
+	root := []struct{ attr, field string }{
+		{"username", "userName"},
+		{"name", "name"},
+	}
+	for _, r := range root {
+		if d.HasChange(r.attr) {
+			operations = append(operations, accountPatchOperation{Op: "replace", Path: accountPatchPath(r.field), Value: d.Get(r.attr)})
 		}
-		prop["PopulateKey"] = populate
 	}
 
-	if len(prop) > 0 {
-		path := "/platformAccountProperties"
-		operation := *gopas.NewOperationAccountModel()
-		operation.Op = &opReplace
-		operation.Path = &path
-		operation.Value = &prop
-		operations = append(operations, operation)
+	o, n := accountPropertiesChange(d, map[string]string{
+		"impersonate_user": "ImpersonateUser",
+		"key_id":           "KeyID",
+	})
+
+	if key != nil {
+		n["KeyID"] = key.PrivateKeyID
 	}
 
-	if len(operations) > 0 {
-		patch := *gopas.NewJsonPatchDocumentAccountModel()
-		patch.SetOperations(operations)
+	// PopulateKey is only stored when populate_key is true, as on create.
+	oldPopulate, newPopulate := d.GetChange("populate_key")
+	if oldPopulate.(bool) {
+		o["PopulateKey"] = "Yes"
+	}
+	if newPopulate.(bool) {
+		n["PopulateKey"] = "Yes"
+	}
 
-		_, resp, err := client.AccountsApi.AccountsUpdateAccount(ctx, id).AccountPatch(patch).Execute()
-		if err != nil {
-			return returnResponseErr(resp, err)
-		}
+	operations = append(operations, accountPropertiesPatch(o, n)...)
+
+	operations = append(operations, accountSecretManagementPatch(d)...)
+
+	if resp, err := updateAccount(ctx, client, id, operations); err != nil {
+		return apiErrorDiags(resp, err)
 	}
 
-	if d.HasChange("change_account") {
-		o, n := d.GetChange("change_account")
-		// if the old account had a value, clear it
-		if o != nil {
-			resp, err := client.AccountsApi.AccountsClearAccount(ctx, id, 2).Execute()
-			if err != nil {
-				return returnResponseErr(resp, err)
-			}
-		}
-		// if the new account has a value, set it
-		if n != nil {
-			changeAccount := n.(*schema.Set).List()[0].(map[string]interface{})
-			safeName := changeAccount["safe_name"].(string)
-			name := changeAccount["name"].(string)
-			folder := changeAccount["folder"].(string)
-			linkAccount := *gopas.NewLinkAccountData(name, safeName, folder, 2)
-
-			resp, err := client.AccountsApi.AccountsLinkAccount(ctx, id).LinkAccount(linkAccount).Execute()
-			if err != nil {
-				return returnResponseErr(resp, err)
-			}
-		}
+	if diags := updateLinkedAccount(ctx, client, d, "change_account", 2); diags.HasError() {
+		return diags
 	}
 
-	if d.HasChange("reconcile_account") {
-		o, n := d.GetChange("reconcile_account")
-		// if the old account had a value, clear it
-		if o != nil {
-			resp, err := client.AccountsApi.AccountsClearAccount(ctx, id, 3).Execute()
-			if err != nil {
-				return returnResponseErr(resp, err)
-			}
-		}
-		// if the new account has a value, set it
-		if n != nil {
-			reconcileAccount := n.(*schema.Set).List()[0].(map[string]interface{})
-			safeName := reconcileAccount["safe_name"].(string)
-			name := reconcileAccount["name"].(string)
-			folder := reconcileAccount["folder"].(string)
-			linkAccount := *gopas.NewLinkAccountData(name, safeName, folder, 3)
-
-			resp, err := client.AccountsApi.AccountsLinkAccount(ctx, id).LinkAccount(linkAccount).Execute()
-			if err != nil {
-				return returnResponseErr(resp, err)
-			}
-		}
+	if diags := updateLinkedAccount(ctx, client, d, "reconcile_account", 3); diags.HasError() {
+		return diags
+	}
+
+	if diags := updateSecret(ctx, client, d, "key_json", "key_json_version"); diags.HasError() {
+		return diags
 	}
 
 	return resourceAccountGCPServiceAccountRead(ctx, d, meta)
@@ -437,12 +366,107 @@ func resourceAccountGCPServiceAccountDelete(ctx context.Context, d *schema.Resou
 
 	id := d.Id()
 
+	// A deletion retried after its response was lost finds the account gone.
 	_, resp, err := client.AccountsApi.AccountsDeleteAccount(ctx, id).Execute()
 	if err != nil {
-		return returnResponseErr(resp, err)
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
 	}
 
 	d.SetId("")
 
 	return nil
 }
+
+// gcpServiceAccountKey is the part of a GCP service account key file used by
+// the provider.
+type gcpServiceAccountKey struct {
+	Type         string `json:"type"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+}
+
+// parseGCPServiceAccountKey parses a GCP service account key file.
+func parseGCPServiceAccountKey(keyJSON string) (*gcpServiceAccountKey, error) {
+	var key gcpServiceAccountKey
+	if err := json.Unmarshal([]byte(keyJSON), &key); err != nil {
+		return nil, fmt.Errorf("not a JSON key file: %w", err)
+	}
+
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("type is %q, not service_account", key.Type)
+	}
+	if key.ClientEmail == "" {
+		return nil, errors.New("client_email is missing")
+	}
+	if key.PrivateKeyID == "" {
+		return nil, errors.New("private_key_id is missing")
+	}
+	if block, _ := pem.Decode([]byte(key.PrivateKey)); block == nil {
+		return nil, errors.New("private_key is not a PEM encoded key")
+	}
+
+	return &key, nil
+}
+
+func validateGCPServiceAccountKey(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if _, err := parseGCPServiceAccountKey(v); err != nil {
+		return nil, []error{fmt.Errorf("%s is not a GCP service account key: %w", k, err)}
+	}
+
+	return nil, nil
+}
+
+// expandGCPServiceAccountKey parses the key_json of the service account in
+// username.
+func expandGCPServiceAccountKey(keyJSON, username string) (*gcpServiceAccountKey, diag.Diagnostics) {
+	key, err := parseGCPServiceAccountKey(keyJSON)
+	if err != nil {
+		return nil, diag.Errorf("key_json is not a GCP service account key: %s", err)
+	}
+
+	if !strings.EqualFold(key.ClientEmail, username) {
+		return nil, diag.Errorf("key_json is a key of %s, not of the service account in username (%s)", key.ClientEmail, username)
+	}
+
+	return key, nil
+}
+
+// customizeDiffGCPServiceAccountKey checks that the configured key_json
+// belongs to the service account in username, and plans the key_id of the key
+// when it will be sent to the Vault.
+func customizeDiffGCPServiceAccountKey(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return nil
+	}
+
+	keyJSON := config.GetAttr("key_json")
+	if keyJSON.IsNull() || !keyJSON.IsKnown() || !d.NewValueKnown("username") {
+		return nil
+	}
+
+	key, diags := expandGCPServiceAccountKey(keyJSON.AsString(), d.Get("username").(string))
+	if diags.HasError() {
+		return errors.New(diags[0].Summary)
+	}
+
+	if d.Get("secret_lifecycle").(string) == secretLifecycleIgnore {
+		return nil
+	}
+	if d.Id() == "" || d.HasChange("key_json_version") {
+		return d.SetNew("key_id", key.PrivateKeyID)
+	}
+
+	return nil
+}
@@ -0,0 +1,403 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccountPropertiesPatch(t *testing.T) {
+	old := map[string]interface{}{
+		"LogonDomain": "EXAMPLE",
+		"Port":        "22",
+		"Location":    "DC1",
+	}
+	new := map[string]interface{}{
+		"LogonDomain":  "EXAMPLE",
+		"Port":         "2222",
+		"Owner/Team":   "platform",
+		"ExtraPass1ID": "1_2",
+	}
+
+	want := []accountPatchOperation{
+		{Op: "add", Path: "/platformAccountProperties/ExtraPass1ID", Value: "1_2"},
+		{Op: "remove", Path: "/platformAccountProperties/Location"},
+		{Op: "add", Path: "/platformAccountProperties/Owner~1Team", Value: "platform"},
+		{Op: "replace", Path: "/platformAccountProperties/Port", Value: "2222"},
+	}
+
+	if got := accountPropertiesPatch(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestAccountSecretManagementPatch(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "unset",
+			raw:  map[string]interface{}{},
+		},
+		{
+			name: "manual",
+			raw: map[string]interface{}{"secret_management": []interface{}{map[string]interface{}{
+				"automatic_management_enabled": false,
+				"manual_management_reason":     "Managed by the application team",
+			}}},
+			want: map[string]interface{}{"automaticManagementEnabled": false, "manualManagementReason": "Managed by the application team"},
+		},
+		{
+			name: "automatic drops reason",
+			raw: map[string]interface{}{"secret_management": []interface{}{map[string]interface{}{
+				"automatic_management_enabled": true,
+				"manual_management_reason":     "stale",
+			}}},
+			want: map[string]interface{}{"automaticManagementEnabled": true},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceAccount().Schema, c.raw)
+
+			var want []accountPatchOperation
+			if c.want != nil {
+				want = []accountPatchOperation{{Op: "replace", Path: "/secretManagement", Value: c.want}}
+			}
+			if got := accountSecretManagementPatch(d); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestAccountRemoteMachinesAccessPatch(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceAccount().Schema, map[string]interface{}{})
+	if got := accountRemoteMachinesAccessPatch(d); got != nil {
+		t.Errorf("got %#v for an unset block, want no operations", got)
+	}
+
+	d = schema.TestResourceDataRaw(t, resourceAccount().Schema, map[string]interface{}{
+		"remote_machines_access": []interface{}{map[string]interface{}{
+			"remote_machines":                      "a.example.com;b.example.com",
+			"access_restricted_to_remote_machines": true,
+		}},
+	})
+	want := []accountPatchOperation{{Op: "add", Path: "/remoteMachinesAccess", Value: map[string]interface{}{
+		"remoteMachines":                   "a.example.com;b.example.com",
+		"accessRestrictedToRemoteMachines": true,
+	}}}
+	if got := accountRemoteMachinesAccessPatch(d); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestSuppressRemoteMachinesDiff(t *testing.T) {
+	cases := []struct {
+		old, new string
+		want     bool
+	}{
+		{"a.example.com;b.example.com", "b.example.com;a.example.com", true},
+		{"a.example.com;b.example.com", "a.example.com; b.example.com;", true},
+		{"a.example.com;b.example.com", "a.example.com", false},
+		{"", "", true},
+		{"", "a.example.com", false},
+	}
+
+	for _, c := range cases {
+		if got := suppressRemoteMachinesDiff("", c.old, c.new, nil); got != c.want {
+			t.Errorf("suppressRemoteMachinesDiff(%q, %q) = %t, want %t", c.old, c.new, got, c.want)
+		}
+	}
+}
+
+func TestSecretVersionsUnmarshal(t *testing.T) {
+	for _, body := range []string{
+		`[{"versionID":1,"isTemporary":false},{"versionID":3,"isTemporary":false},{"versionID":4,"isTemporary":true}]`,
+		`{"Versions":[{"versionID":1,"isTemporary":false},{"versionID":3,"isTemporary":false},{"versionID":4,"isTemporary":true}]}`,
+	} {
+		var versions secretVersions
+		if err := json.Unmarshal([]byte(body), &versions); err != nil {
+			t.Fatalf("%s: %s", body, err)
+		}
+
+		want := secretVersions{{VersionID: 1}, {VersionID: 3}, {VersionID: 4, IsTemporary: true}}
+		if !reflect.DeepEqual(versions, want) {
+			t.Errorf("%s: got %#v, want %#v", body, versions, want)
+		}
+	}
+}
+
+func TestSecretDrifted(t *testing.T) {
+	cases := []struct {
+		managed, vault int
+		want           bool
+	}{
+		{0, 0, false},
+		{0, 3, false},
+		{3, 0, false},
+		{3, 3, false},
+		{3, 4, true},
+	}
+
+	for _, c := range cases {
+		if got := secretDrifted(c.managed, c.vault); got != c.want {
+			t.Errorf("secretDrifted(%d, %d) = %t, want %t", c.managed, c.vault, got, c.want)
+		}
+	}
+}
+
+func TestAccResourceAccount(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheckWriteOnly(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		CheckDestroy:             testAccCheckAccountDestroy("pas_account"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceAccount(safeName, `Port = "22"`, `automatic_management_enabled = true`, "a.example.com;b.example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckNoResourceAttr("pas_account.test", "secret"),
+					resource.TestCheckResourceAttrPair("pas_account.test", "managed_secret_version_id", "pas_account.test", "vault_secret_version_id"),
+					resource.TestCheckResourceAttr("pas_account.test", "secret_management.0.automatic_management_enabled", "true"),
+					resource.TestCheckResourceAttr("pas_account.test", "remote_machines_access.0.access_restricted_to_remote_machines", "true"),
+					resource.TestCheckResourceAttr("pas_account.test", "platform_account_properties.%", "1"),
+					resource.TestCheckResourceAttr("pas_account.test", "platform_account_properties.Port", "22"),
+				),
+			},
+			{
+				Config: testAccResourceAccount(safeName, `Location = "DC1"`, `
+    automatic_management_enabled = false
+    manual_management_reason     = "Managed by Terraform tests"`, "b.example.com;a.example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_account.test", "secret_management.0.automatic_management_enabled", "false"),
+					resource.TestCheckResourceAttr("pas_account.test", "secret_management.0.manual_management_reason", "Managed by Terraform tests"),
+					resource.TestCheckResourceAttr("pas_account.test", "platform_account_properties.%", "1"),
+					resource.TestCheckResourceAttr("pas_account.test", "platform_account_properties.Location", "DC1"),
+				),
+			},
+			{
+				Config: testAccResourceAccount(safeName, `Location = "DC1"`, "", ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_account.test", "secret_management.0.automatic_management_enabled", "false"),
+					resource.TestCheckResourceAttr("pas_account.test", "secret_management.0.manual_management_reason", "Managed by Terraform tests"),
+					resource.TestCheckResourceAttr("pas_account.test", "remote_machines_access.0.access_restricted_to_remote_machines", "true"),
+				),
+			},
+			{
+				ResourceName:            "pas_account.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"secret_version", "secret_lifecycle", "managed_secret_version_id", "vault_secret_version_id"},
+			},
+			{
+				ResourceName:            "pas_account.test",
+				ImportState:             true,
+				ImportStateIdFunc:       testAccAccountImportStateIDFunc("pas_account.test"),
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"secret_version", "secret_lifecycle", "managed_secret_version_id", "vault_secret_version_id"},
+			},
+		},
+	})
+}
+
+func TestAccResourceAccountSecretLifecycle(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	var id string
+	captureID := resource.TestCheckResourceAttrWith("pas_account.test", "id", func(v string) error {
+		id = v
+		return nil
+	})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheckWriteOnly(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		CheckDestroy:             testAccCheckAccountDestroy("pas_account"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceAccountSecretLifecycle(safeName, "enforce"),
+				Check: resource.ComposeTestCheckFunc(
+					captureID,
+					testAccCheckAccountSecret("pas_account.test", "Initial-Passw0rd"),
+					testAccCheckAccountSecret("pas_account.ignored", ""),
+				),
+			},
+			{
+				PreConfig:          testAccRotateAccountSecret(t, &id, "Rotated-Passw0rd"),
+				Config:             testAccResourceAccountSecretLifecycle(safeName, "enforce"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccResourceAccountSecretLifecycle(safeName, "enforce"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAccountSecret("pas_account.test", "Initial-Passw0rd"),
+					resource.TestCheckResourceAttrPair("pas_account.test", "managed_secret_version_id", "pas_account.test", "vault_secret_version_id"),
+				),
+			},
+			{
+				Config: testAccResourceAccountSecretLifecycle(safeName, "initial_only"),
+				Check:  resource.TestCheckResourceAttr("pas_account.test", "managed_secret_version_id", "0"),
+			},
+			{
+				PreConfig: testAccRotateAccountSecret(t, &id, "Rotated-Passw0rd"),
+				Config:    testAccResourceAccountSecretLifecycle(safeName, "initial_only"),
+				PlanOnly:  true,
+			},
+		},
+	})
+}
+
+// testAccRotateAccountSecret returns a PreConfig function that changes the
+// secret of the account with the ID *id outside of Terraform, as a CPM
+// rotation would.
+func testAccRotateAccountSecret(t *testing.T, id *string, secret string) func() {
+	return func() {
+		client := testAccProvider().Meta().(*apiClient).Client
+
+		if resp, err := setAccountSecret(context.Background(), client, *id, secret); err != nil {
+			t.Fatal(newAPIError(resp, err))
+		}
+	}
+}
+
+// testAccAccountImportStateIDFunc returns the safe_name/account_name import ID
+// of an account resource.
+func testAccAccountImportStateIDFunc(resourceName string) resource.ImportStateIdFunc {
+	return func(s *terraform.State) (string, error) {
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return "", fmt.Errorf("resource not found: %s", resourceName)
+		}
+
+		return rs.Primary.Attributes["safe_name"] + "/" + rs.Primary.Attributes["name"], nil
+	}
+}
+
+// testAccCheckAccountSecret checks that the secret of an account resource in
+// the Vault is want.
+func testAccCheckAccountSecret(resourceName, want string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", resourceName)
+		}
+
+		client := testAccProvider().Meta().(*apiClient).Client
+
+		secret, resp, err := retrieveAccountSecret(context.Background(), client, rs.Primary.ID, accountSecretRetrieveRequest{Reason: "Terraform acceptance test"})
+		if err != nil {
+			return newAPIError(resp, err)
+		}
+		if secret != want {
+			return fmt.Errorf("secret of %s is %q, want %q", resourceName, secret, want)
+		}
+
+		return nil
+	}
+}
+
+// testAccCheckAccountDestroy checks that the accounts of a resource type no
+// longer exist.
+func testAccCheckAccountDestroy(resourceType string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		client := testAccProvider().Meta().(*apiClient).Client
+
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != resourceType {
+				continue
+			}
+
+			_, resp, err := client.AccountsApi.AccountsGetAccount(context.Background(), rs.Primary.ID).Execute()
+			if err == nil {
+				return fmt.Errorf("account %s still exists", rs.Primary.ID)
+			}
+			if !isNotFound(resp) {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// testAccResourceAccount returns the config of a pas_account. The
+// secret_management and remote_machines_access blocks are left out if
+// secretManagement and remoteMachines are empty.
+func testAccResourceAccount(safeName, properties, secretManagement, remoteMachines string) string {
+	if secretManagement != "" {
+		secretManagement = "secret_management {\n    " + secretManagement + "\n  }"
+	}
+	if remoteMachines != "" {
+		remoteMachines = fmt.Sprintf("remote_machines_access {\n    remote_machines                      = %q\n    access_restricted_to_remote_machines = true\n  }", remoteMachines)
+	}
+
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_account" "test" {
+  safe_name   = pas_safe.test.safe_name
+  platform_id = "UnixSSH"
+  address     = "host.example.com"
+  username    = "svc_terraform"
+
+  secret           = "Initial-Passw0rd"
+  secret_version   = 1
+  secret_lifecycle = "enforce"
+
+  platform_account_properties = {
+    %[2]s
+  }
+
+  %[3]s
+
+  %[4]s
+}
+`, safeName, properties, secretManagement, remoteMachines)
+}
+
+func testAccResourceAccountSecretLifecycle(safeName, lifecycle string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_account" "test" {
+  safe_name   = pas_safe.test.safe_name
+  platform_id = "UnixSSH"
+  address     = "host.example.com"
+  username    = "svc_lifecycle"
+
+  secret           = "Initial-Passw0rd"
+  secret_version   = 1
+  secret_lifecycle = %[2]q
+}
+
+resource "pas_account" "ignored" {
+  safe_name   = pas_safe.test.safe_name
+  platform_id = "UnixSSH"
+  address     = "host.example.com"
+  username    = "svc_ignored"
+
+  secret           = "Ignored-Passw0rd"
+  secret_version   = 1
+  secret_lifecycle = "ignore"
+}
+`, safeName, lifecycle)
+}
@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/umich-vci/gopas"
+)
+
+func resourceSafe() *schema.Resource {
+	return &schema.Resource{
+		Description: "Resource to manage a safe in CyberArk PAS",
+
+		CreateContext: resourceSafeCreate,
+		ReadContext:   resourceSafeRead,
+		UpdateContext: resourceSafeUpdate,
+		DeleteContext: resourceSafeDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		// Object Level Access Control cannot be turned off once it has been enabled on a safe.
+		CustomizeDiff: customdiff.ForceNewIfChange("olac_enabled", func(ctx context.Context, old, new, meta interface{}) bool {
+			return old.(bool) && !new.(bool)
+		}),
+
+		Schema: map[string]*schema.Schema{
+			"safe_name": {
+				Description:  "The name of the safe. This can be up to 28 characters long.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.All(validation.StringLenBetween(1, 28), validation.StringDoesNotContainAny(`\/:*?"<>|`)),
+			},
+			"description": {
+				Description: "The description of the safe.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"location": {
+				Description: "The location of the safe in the Vault.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     `\`,
+			},
+			"managing_cpm": {
+				Description: "The name of the CPM user who will manage the safe. If not specified, the safe is not managed by a CPM.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"olac_enabled": {
+				Description: "Whether to enable Object Level Access Control for the safe. This cannot be disabled once enabled, so turning it off forces a new safe.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"number_of_versions_retention": {
+				Description:   "The number of retained versions of every password stored in the safe. Conflicts with `number_of_days_retention`.",
+				Type:          schema.TypeInt,
+				Optional:      true,
+				Computed:      true,
+				ValidateFunc:  validation.IntBetween(1, 999),
+				ConflictsWith: []string{"number_of_days_retention"},
+			},
+			"number_of_days_retention": {
+				Description:   "The number of days that password versions are saved in the safe. Conflicts with `number_of_versions_retention`.",
+				Type:          schema.TypeInt,
+				Optional:      true,
+				Computed:      true,
+				ValidateFunc:  validation.IntBetween(1, 3650),
+				ConflictsWith: []string{"number_of_versions_retention"},
+			},
+			"auto_purge_enabled": {
+				Description: "Whether to automatically purge files after the end of the object history retention period. This can only be set when the safe is created.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"safe_number": {
+				Description: "The unique numerical ID of the safe.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"creation_time": {
+				Description: "When the safe was created, in Unix time.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"last_modification_time": {
+				Description: "When the safe was last updated, in Unix time.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// addSafeRequest is the body of an Add Safe request. It is used instead of
+// gopas.AddSafeRequest because that model has no autoPurgeEnabled field.
+type addSafeRequest struct {
+	SafeName                  string  `json:"safeName"`
+	Description               *string `json:"description,omitempty"`
+	Location                  *string `json:"location,omitempty"`
+	ManagingCPM               *string `json:"managingCPM,omitempty"`
+	OLACEnabled               *bool   `json:"olacEnabled,omitempty"`
+	NumberOfVersionsRetention *int32  `json:"numberOfVersionsRetention,omitempty"`
+	NumberOfDaysRetention     *int32  `json:"numberOfDaysRetention,omitempty"`
+	AutoPurgeEnabled          *bool   `json:"autoPurgeEnabled,omitempty"`
+}
+
+func resourceSafeCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safe := addSafeRequest{
+		SafeName: d.Get("safe_name").(string),
+	}
+
+	if v, ok := d.GetOk("description"); ok {
+		description := v.(string)
+		safe.Description = &description
+	}
+
+	location := d.Get("location").(string)
+	safe.Location = &location
+
+	if v, ok := d.GetOk("managing_cpm"); ok {
+		cpm := v.(string)
+		safe.ManagingCPM = &cpm
+	}
+
+	olac := d.Get("olac_enabled").(bool)
+	safe.OLACEnabled = &olac
+
+	if v, ok := d.GetOk("number_of_versions_retention"); ok {
+		versions := int32(v.(int))
+		safe.NumberOfVersionsRetention = &versions
+	}
+
+	if v, ok := d.GetOk("number_of_days_retention"); ok {
+		days := int32(v.(int))
+		safe.NumberOfDaysRetention = &days
+	}
+
+	autoPurge := d.Get("auto_purge_enabled").(bool)
+	safe.AutoPurgeEnabled = &autoPurge
+
+	// A create that is retried after it succeeded is rejected because the
+	// safe exists, in which case the safe created by the first attempt is
+	// used.
+	createCtx, create := withDetectableCreate(ctx)
+
+	var created gopas.AddSafeResponse
+	resp, err := apiRequest(createCtx, client, http.MethodPost, "/api/Safes", nil, safe, &created)
+	switch {
+	case err == nil:
+		d.SetId(created.GetSafeUrlId())
+	case create.duplicated(resp):
+		d.SetId(url.PathEscape(safe.SafeName))
+	default:
+		return apiErrorDiags(resp, err)
+	}
+
+	return resourceSafeRead(ctx, d, meta)
+}
+
+func resourceSafeRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Id()
+
+	safe, resp, err := client.SafesApi.SafesGetSafeDetails(ctx, id).Execute()
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	d.Set("safe_name", safe.SafeName)
+	d.Set("description", safe.Description)
+	d.Set("location", safe.Location)
+	d.Set("managing_cpm", safe.ManagingCPM)
+	d.Set("olac_enabled", safe.OlacEnabled)
+	d.Set("number_of_versions_retention", safe.NumberOfVersionsRetention)
+	d.Set("number_of_days_retention", safe.NumberOfDaysRetention)
+	d.Set("auto_purge_enabled", safe.AutoPurgeEnabled)
+	d.Set("safe_number", safe.SafeNumber)
+	d.Set("creation_time", safe.CreationTime)
+	d.Set("last_modification_time", safe.LastModificationTime)
+
+	return nil
+}
+
+func resourceSafeUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Id()
+
+	safeName := d.Get("safe_name").(string)
+	description := d.Get("description").(string)
+	location := d.Get("location").(string)
+	cpm := d.Get("managing_cpm").(string)
+	olac := d.Get("olac_enabled").(bool)
+
+	safe := *gopas.NewUpdateSafeRequestBody()
+	safe.SafeName = &safeName
+	safe.Description = &description
+	safe.Location = &location
+	safe.ManagingCPM = &cpm
+	safe.OLACEnabled = &olac
+
+	// Only one retention setting may be sent, so only send the one that changed.
+	if d.HasChange("number_of_versions_retention") {
+		versions := int32(d.Get("number_of_versions_retention").(int))
+		safe.NumberOfVersionsRetention = &versions
+	}
+
+	if d.HasChange("number_of_days_retention") {
+		days := int32(d.Get("number_of_days_retention").(int))
+		safe.NumberOfDaysRetention = &days
+	}
+
+	updated, resp, err := client.SafesApi.SafesUpdateSafe(ctx, id).UpdateSafeRequestBody(safe).Execute()
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	// Renaming a safe changes its URL ID.
+	if updated.SafeUrlId != nil {
+		d.SetId(*updated.SafeUrlId)
+	}
+
+	return resourceSafeRead(ctx, d, meta)
+}
+
+func resourceSafeDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	id := d.Id()
+
+	_, resp, err := client.SafesApi.SafesDeleteSafe(ctx, id).Execute()
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		// The Vault keeps a safe until the retention period of its contents has
+		// passed. The safe is already marked for deletion, so this is not a failure.
+		if e := responseError(resp); isSafeRetentionErr(e) {
+			d.SetId("")
+			return diag.Diagnostics{{
+				Severity: diag.Warning,
+				Summary:  "Safe is pending deletion",
+				Detail:   "The safe " + d.Get("safe_name").(string) + " will be removed by the Vault once the retention period of its contents has passed: " + e.ErrorMessage,
+			}}
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	d.SetId("")
+
+	return nil
+}
+
+// safeRetentionErrorCode is the Vault error returned when a safe is marked for
+// deletion but kept until objects in it reach the end of their retention
+// period.
+const safeRetentionErrorCode = "ITATS528E"
+
+// isSafeRetentionErr reports whether a safe deletion was refused because
+// objects in the safe have not yet reached the end of their retention period.
+func isSafeRetentionErr(e pasError) bool {
+	return e.ErrorCode == safeRetentionErrorCode
+}
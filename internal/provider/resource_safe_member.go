@@ -0,0 +1,400 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/umich-vci/gopas"
+)
+
+// safeMemberPermissions maps the permission attributes of a safe member to the
+// permission names used by the Safes API.
+var safeMemberPermissions = map[string]string{
+	"use_accounts":                    "useAccounts",
+	"retrieve_accounts":               "retrieveAccounts",
+	"list_accounts":                   "listAccounts",
+	"add_accounts":                    "addAccounts",
+	"update_account_content":          "updateAccountContent",
+	"update_account_properties":       "updateAccountProperties",
+	"initiate_cpm_account_management": "initiateCPMAccountManagementOperations",
+	"specify_next_account_content":    "specifyNextAccountContent",
+	"rename_accounts":                 "renameAccounts",
+	"delete_accounts":                 "deleteAccounts",
+	"unlock_accounts":                 "unlockAccounts",
+	"manage_safe":                     "manageSafe",
+	"manage_safe_members":             "manageSafeMembers",
+	"backup_safe":                     "backupSafe",
+	"view_audit_log":                  "viewAuditLog",
+	"view_safe_members":               "viewSafeMembers",
+	"access_without_confirmation":     "accessWithoutConfirmation",
+	"create_folders":                  "createFolders",
+	"delete_folders":                  "deleteFolders",
+	"move_accounts_and_folders":       "moveAccountsAndFolders",
+}
+
+// safeMemberPresets are the named permission sets that permission_preset can
+// expand to. Any permission not listed is false, and requests_authorization_level
+// is 0 unless listed in safeMemberPresetLevels.
+var safeMemberPresets = map[string][]string{
+	"connect-only": {"use_accounts", "list_accounts"},
+	"read-only":    {"list_accounts", "view_audit_log", "view_safe_members"},
+	"end-user":     {"use_accounts", "retrieve_accounts", "list_accounts", "view_audit_log", "view_safe_members"},
+	"approver":     {"list_accounts", "view_audit_log", "view_safe_members", "manage_safe_members"},
+	"accounts-manager": {
+		"use_accounts", "retrieve_accounts", "list_accounts", "add_accounts", "update_account_content",
+		"update_account_properties", "initiate_cpm_account_management", "specify_next_account_content",
+		"rename_accounts", "delete_accounts", "unlock_accounts", "view_audit_log", "view_safe_members",
+		"manage_safe_members", "create_folders", "delete_folders", "move_accounts_and_folders",
+	},
+	"full": {
+		"use_accounts", "retrieve_accounts", "list_accounts", "add_accounts", "update_account_content",
+		"update_account_properties", "initiate_cpm_account_management", "specify_next_account_content",
+		"rename_accounts", "delete_accounts", "unlock_accounts", "manage_safe", "manage_safe_members",
+		"backup_safe", "view_audit_log", "view_safe_members", "access_without_confirmation",
+		"create_folders", "delete_folders", "move_accounts_and_folders",
+	},
+}
+
+var safeMemberPresetLevels = map[string]int{
+	"approver": 1,
+	"full":     1,
+}
+
+func resourceSafeMember() *schema.Resource {
+	s := map[string]*schema.Schema{
+		"safe_name": {
+			Description:  "The name of the safe to add the member to.",
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringIsNotEmpty,
+		},
+		"member_name": {
+			Description:  "The Vault user name, domain user name, group name or role name of the member.",
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringIsNotEmpty,
+		},
+		"member_type": {
+			Description:      "The type of the member. Valid values are `User`, `Group` and `Role`.",
+			Type:             schema.TypeString,
+			Optional:         true,
+			ForceNew:         true,
+			Default:          "User",
+			ValidateFunc:     validation.StringInSlice([]string{"User", "Group", "Role"}, true),
+			DiffSuppressFunc: suppressEqualFold,
+		},
+		"search_in": {
+			Description: "The Vault or domain where the member should be searched for. If not specified, the Vault is searched.",
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"membership_expiration_date": {
+			Description: "When the membership expires, in Unix time. If not specified, the membership does not expire.",
+			Type:        schema.TypeInt,
+			Optional:    true,
+		},
+		"permission_preset": {
+			Description:  "A named set of permissions to grant. Valid values are `connect-only`, `read-only`, `end-user`, `approver`, `accounts-manager` and `full`. Permission attributes that are set explicitly override the preset.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice(safeMemberPresetNames(), false),
+		},
+		"requests_authorization_level": {
+			Description:  "The request authorization level of the member. `0` means the member cannot authorize requests, `1` and `2` are the two confirmation levels. If not specified, this is taken from `permission_preset`, or `0`.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Computed:     true,
+			ValidateFunc: validation.IntBetween(0, 2),
+		},
+		"is_predefined_user": {
+			Description: "Whether the member is a built-in Vault user or group.",
+			Type:        schema.TypeBool,
+			Computed:    true,
+		},
+		"is_expired_membership": {
+			Description: "Whether the membership has expired.",
+			Type:        schema.TypeBool,
+			Computed:    true,
+		},
+	}
+
+	for attr, permission := range safeMemberPermissions {
+		s[attr] = &schema.Schema{
+			Description: fmt.Sprintf("Whether the member has the `%s` permission. If not specified, this is taken from `permission_preset`, or `false`.", permission),
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Computed:    true,
+		}
+	}
+
+	return &schema.Resource{
+		Description: "Resource to manage a member of a safe and its permissions in CyberArk PAS",
+
+		CreateContext: resourceSafeMemberCreate,
+		ReadContext:   resourceSafeMemberRead,
+		UpdateContext: resourceSafeMemberUpdate,
+		DeleteContext: resourceSafeMemberDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSafeMemberImport,
+		},
+
+		CustomizeDiff: resourceSafeMemberCustomizeDiff,
+
+		Schema: s,
+	}
+}
+
+func safeMemberPresetNames() []string {
+	names := make([]string, 0, len(safeMemberPresets))
+	for name := range safeMemberPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandSafeMemberPermissions returns the permission attributes and request
+// authorization level described by a preset, overridden by any of those
+// attributes that are set in config.
+func expandSafeMemberPermissions(preset string, config cty.Value) (map[string]bool, int) {
+	permissions := make(map[string]bool, len(safeMemberPermissions))
+	for attr := range safeMemberPermissions {
+		permissions[attr] = false
+	}
+	for _, attr := range safeMemberPresets[preset] {
+		permissions[attr] = true
+	}
+	level := safeMemberPresetLevels[preset]
+
+	if config.IsNull() || !config.IsKnown() {
+		return permissions, level
+	}
+
+	for attr := range safeMemberPermissions {
+		if v := config.GetAttr(attr); !v.IsNull() && v.IsKnown() {
+			permissions[attr] = v.True()
+		}
+	}
+
+	if v := config.GetAttr("requests_authorization_level"); !v.IsNull() && v.IsKnown() {
+		l, _ := v.AsBigFloat().Int64()
+		level = int(l)
+	}
+
+	return permissions, level
+}
+
+// resourceSafeMemberCustomizeDiff plans the permissions the member should have,
+// so that permissions changed outside of Terraform show up as drift even when
+// they come from a preset rather than being set explicitly.
+func resourceSafeMemberCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	permissions, level := expandSafeMemberPermissions(d.Get("permission_preset").(string), d.GetRawConfig())
+
+	for attr, want := range permissions {
+		if d.Id() == "" || d.Get(attr).(bool) != want {
+			if err := d.SetNew(attr, want); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.Id() == "" || d.Get("requests_authorization_level").(int) != level {
+		if err := d.SetNew("requests_authorization_level", level); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// safeMemberPermissionsFromResourceData builds the permissions body of an add
+// or update member request.
+func safeMemberPermissionsFromResourceData(d *schema.ResourceData) map[string]bool {
+	permissions := make(map[string]bool, len(safeMemberPermissions)+2)
+	for attr, permission := range safeMemberPermissions {
+		permissions[permission] = d.Get(attr).(bool)
+	}
+
+	level := d.Get("requests_authorization_level").(int)
+	permissions["requestsAuthorizationLevel1"] = level == 1
+	permissions["requestsAuthorizationLevel2"] = level == 2
+
+	return permissions
+}
+
+// addSafeMemberRequest is the body of an Add Safe Member request. It is used
+// instead of gopas.AddSafeMemberRequestBody because that model has no memberType
+// field, so groups and roles could not be added.
+type addSafeMemberRequest struct {
+	MemberName               string          `json:"memberName"`
+	MemberType               string          `json:"memberType"`
+	SearchIn                 *string         `json:"searchIn,omitempty"`
+	MembershipExpirationDate *int64          `json:"membershipExpirationDate,omitempty"`
+	Permissions              map[string]bool `json:"permissions"`
+}
+
+// updateSafeMemberRequest is the body of an Update Safe Member request. It is
+// used instead of gopas.UpdateSafeMemberRequestBody because that model omits
+// an unset membershipExpirationDate, which leaves the date unchanged instead
+// of clearing it.
+type updateSafeMemberRequest struct {
+	MembershipExpirationDate *int64          `json:"membershipExpirationDate"`
+	Permissions              map[string]bool `json:"permissions"`
+}
+
+// updateSafeMember sets the permissions and membership expiration date of a
+// safe member. A nil MembershipExpirationDate is sent as null, so that the
+// membership no longer expires.
+func updateSafeMember(ctx context.Context, client gopas.APIClient, safeName, memberName string, update updateSafeMemberRequest) (*http.Response, error) {
+	path := fmt.Sprintf("/api/Safes/%s/members/%s", url.PathEscape(safeName), url.PathEscape(memberName))
+	return apiRequest(ctx, client, http.MethodPut, path, nil, update, nil)
+}
+
+func resourceSafeMemberCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Get("safe_name").(string)
+	memberName := d.Get("member_name").(string)
+
+	member := addSafeMemberRequest{
+		MemberName:  memberName,
+		MemberType:  d.Get("member_type").(string),
+		Permissions: safeMemberPermissionsFromResourceData(d),
+	}
+
+	if v, ok := d.GetOk("search_in"); ok {
+		searchIn := v.(string)
+		member.SearchIn = &searchIn
+	}
+
+	if v, ok := d.GetOk("membership_expiration_date"); ok {
+		expiration := int64(v.(int))
+		member.MembershipExpirationDate = &expiration
+	}
+
+	// A create that is retried after it succeeded is rejected because the
+	// member exists, in which case the member added by the first attempt is
+	// used.
+	createCtx, create := withDetectableCreate(ctx)
+
+	path := fmt.Sprintf("/api/Safes/%s/Members", url.PathEscape(safeName))
+	resp, err := apiRequest(createCtx, client, http.MethodPost, path, nil, member, nil)
+	if err != nil && !create.duplicated(resp) {
+		return apiErrorDiags(resp, err)
+	}
+
+	d.SetId(safeName + "/" + memberName)
+
+	return resourceSafeMemberRead(ctx, d, meta)
+}
+
+func resourceSafeMemberRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Get("safe_name").(string)
+	memberName := d.Get("member_name").(string)
+
+	member, resp, err := client.SafesApi.SafesGetSafeMember(ctx, safeName, memberName).Execute()
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	permissions := map[string]bool{}
+	if member.Permissions != nil {
+		permissions = *member.Permissions
+	}
+
+	for attr, permission := range safeMemberPermissions {
+		d.Set(attr, permissions[permission])
+	}
+
+	level := 0
+	if permissions["requestsAuthorizationLevel1"] {
+		level = 1
+	} else if permissions["requestsAuthorizationLevel2"] {
+		level = 2
+	}
+	d.Set("requests_authorization_level", level)
+
+	d.Set("safe_name", member.SafeName)
+	d.Set("member_name", member.MemberName)
+	if member.MemberType != nil {
+		d.Set("member_type", member.MemberType)
+	}
+	d.Set("membership_expiration_date", member.MembershipExpirationDate)
+	d.Set("is_predefined_user", member.IsPredefinedUser)
+	d.Set("is_expired_membership", member.IsExpiredMembershipEnable)
+
+	return nil
+}
+
+func resourceSafeMemberUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Get("safe_name").(string)
+	memberName := d.Get("member_name").(string)
+
+	update := updateSafeMemberRequest{Permissions: safeMemberPermissionsFromResourceData(d)}
+
+	if v, ok := d.GetOk("membership_expiration_date"); ok {
+		expiration := int64(v.(int))
+		update.MembershipExpirationDate = &expiration
+	}
+
+	resp, err := updateSafeMember(ctx, client, safeName, memberName, update)
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	return resourceSafeMemberRead(ctx, d, meta)
+}
+
+func resourceSafeMemberDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Get("safe_name").(string)
+	memberName := d.Get("member_name").(string)
+
+	_, resp, err := client.SafesApi.SafesDeleteSafeMember(ctx, safeName, memberName).Execute()
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	d.SetId("")
+
+	return nil
+}
+
+func resourceSafeMemberImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	safeName, memberName, ok := strings.Cut(d.Id(), "/")
+	if !ok || safeName == "" || memberName == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected safe_name/member_name", d.Id())
+	}
+
+	d.Set("safe_name", safeName)
+	d.Set("member_name", memberName)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestExpandSafeMemberPermissions(t *testing.T) {
+	config := map[string]cty.Value{
+		"requests_authorization_level": cty.NullVal(cty.Number),
+	}
+	for attr := range safeMemberPermissions {
+		config[attr] = cty.NullVal(cty.Bool)
+	}
+	config["retrieve_accounts"] = cty.True
+	config["view_safe_members"] = cty.False
+
+	permissions, level := expandSafeMemberPermissions("read-only", cty.ObjectVal(config))
+
+	want := map[string]bool{
+		"list_accounts":     true,
+		"view_audit_log":    true,
+		"retrieve_accounts": true,
+	}
+	for attr := range safeMemberPermissions {
+		if permissions[attr] != want[attr] {
+			t.Errorf("%s: got %t, want %t", attr, permissions[attr], want[attr])
+		}
+	}
+	if level != 0 {
+		t.Errorf("requests_authorization_level: got %d, want 0", level)
+	}
+
+	_, level = expandSafeMemberPermissions("full", cty.NullVal(cty.DynamicPseudoType))
+	if level != 1 {
+		t.Errorf("requests_authorization_level: got %d, want 1", level)
+	}
+}
+
+func TestResourceSafeMemberDeleteRemoved(t *testing.T) {
+	client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"ErrorCode":"PASWS013E","ErrorMessage":"Member Auditors was not found in safe Linux."}`)
+	})
+
+	d := resourceSafeMember().TestResourceData()
+	d.SetId("Linux/Auditors")
+	d.Set("safe_name", "Linux")
+	d.Set("member_name", "Auditors")
+
+	if diags := resourceSafeMemberDelete(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("got ID %q, want the member removed from the state", d.Id())
+	}
+}
+
+func TestAccResourceSafeMember(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceSafeMember(safeName, "read-only", ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_member.test", "list_accounts", "true"),
+					resource.TestCheckResourceAttr("pas_safe_member.test", "retrieve_accounts", "false"),
+				),
+			},
+			{
+				Config: testAccResourceSafeMember(safeName, "read-only", "retrieve_accounts = true"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_member.test", "retrieve_accounts", "true"),
+				),
+			},
+			{
+				Config: testAccResourceSafeMember(safeName, "read-only", `
+  retrieve_accounts          = true
+  membership_expiration_date = 4102444800`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_member.test", "membership_expiration_date", "4102444800"),
+				),
+			},
+			{
+				Config: testAccResourceSafeMember(safeName, "read-only", "retrieve_accounts = true"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_member.test", "membership_expiration_date", "0"),
+				),
+			},
+			{
+				ResourceName:            "pas_safe_member.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"permission_preset"},
+			},
+		},
+	})
+}
+
+func testAccResourceSafeMember(safeName, preset, extra string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_safe_member" "test" {
+  safe_name         = pas_safe.test.safe_name
+  member_name       = "Auditors"
+  member_type       = "Group"
+  permission_preset = %[2]q
+  %[3]s
+}
+`, safeName, preset, extra)
+}
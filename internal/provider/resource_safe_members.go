@@ -0,0 +1,487 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/umich-vci/gopas"
+)
+
+func resourceSafeMembers() *schema.Resource {
+	member := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"member_name": {
+				Description:  "The Vault user name, domain user name, group name or role name of the member.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"member_type": {
+				Description:      "The type of the member. Valid values are `User`, `Group` and `Role`.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "User",
+				ValidateFunc:     validation.StringInSlice([]string{"User", "Group", "Role"}, true),
+				DiffSuppressFunc: suppressEqualFold,
+			},
+			"search_in": {
+				Description: "The Vault or domain where the member should be searched for when it is added. If not specified, the Vault is searched.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"membership_expiration_date": {
+				Description: "When the membership expires, in Unix time. If not specified, the membership does not expire.",
+				Type:        schema.TypeInt,
+				Optional:    true,
+			},
+			"permissions": {
+				Description: "The permissions of the member. These use the permission attribute names of `pas_safe_member`, such as `list_accounts` and `use_accounts`.",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(safeMemberPermissionNames(), false),
+				},
+			},
+			"requests_authorization_level": {
+				Description:  "The request authorization level of the member. `0` means the member cannot authorize requests, `1` and `2` are the two confirmation levels.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntBetween(0, 2),
+			},
+		},
+	}
+
+	return &schema.Resource{
+		Description: "Resource to authoritatively manage the complete list of members of a safe in CyberArk PAS. " +
+			"Members of the safe that are not listed are removed, except for built-in members and members listed in `ignored_members`. " +
+			"The Vault adds the user that creates a safe as a member, so the user Terraform logs on with should be listed in `member` or `ignored_members`. " +
+			"When the resource is destroyed, the user Terraform logs on with is left in the safe. " +
+			"Finding that user needs the legacy Logged on User API of the PVWA; where it is not available, such as on Privileged Cloud, a warning is shown and the user may be removed like any other member. " +
+			"Do not use this resource together with `pas_safe_member` resources for the same safe.",
+
+		CreateContext: resourceSafeMembersCreate,
+		ReadContext:   resourceSafeMembersRead,
+		UpdateContext: resourceSafeMembersUpdate,
+		DeleteContext: resourceSafeMembersDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSafeMembersImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"safe_name": {
+				Description:  "The name of the safe whose members are managed.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"member": {
+				Description: "A member of the safe.",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        member,
+				Set:         safeMembersMemberHash(member),
+			},
+			"exclude_predefined_members": {
+				Description: "Whether to leave built-in Vault members such as `Master` and `Batch` alone rather than removing them.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"ignored_members": {
+				Description: "Names of members that are not managed by this resource, such as the CPM user of the safe. These are never removed and are not reported as drift.",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// safeMembersMemberHash returns the hash function of the member set. It
+// hashes member_type regardless of case, so that a member whose type is
+// configured in another case than the API returns is not seen as a different
+// member.
+func safeMembersMemberHash(member *schema.Resource) schema.SchemaSetFunc {
+	hash := schema.HashResource(member)
+	return func(v interface{}) int {
+		m := map[string]interface{}{}
+		for k, value := range v.(map[string]interface{}) {
+			m[k] = value
+		}
+		if memberType, ok := m["member_type"].(string); ok {
+			m["member_type"] = strings.ToLower(memberType)
+		}
+		return hash(m)
+	}
+}
+
+func safeMemberPermissionNames() []string {
+	names := make([]string, 0, len(safeMemberPermissions))
+	for attr := range safeMemberPermissions {
+		names = append(names, attr)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// safeMembersPage is a page of the Get Safe Members response. gopas models
+// this response as a single member, so it is decoded here instead.
+type safeMembersPage struct {
+	Value    []gopas.SafeMemberResponse `json:"value"`
+	Count    int                        `json:"count"`
+	NextLink *string                    `json:"nextLink"`
+}
+
+// listSafeMembers returns every member of a safe, including built-in members.
+func listSafeMembers(ctx context.Context, client gopas.APIClient, safeName string) ([]gopas.SafeMemberResponse, *http.Response, error) {
+	const limit = 100
+
+	path := fmt.Sprintf("/api/Safes/%s/Members", url.PathEscape(safeName))
+	members := []gopas.SafeMemberResponse{}
+
+	for {
+		query := url.Values{}
+		query.Set("filter", "includePredefinedUsers eq true")
+		query.Set("offset", strconv.Itoa(len(members)))
+		query.Set("limit", strconv.Itoa(limit))
+
+		var page safeMembersPage
+		resp, err := apiRequest(ctx, client, http.MethodGet, path, query, nil, &page)
+		if err != nil {
+			return nil, resp, err
+		}
+
+		members = append(members, page.Value...)
+
+		if len(page.Value) == 0 || len(members) >= page.Count {
+			return members, resp, nil
+		}
+	}
+}
+
+// safeMembersManaged reports whether a member is one that this resource owns,
+// rather than a built-in or ignored member.
+func safeMembersManaged(d *schema.ResourceData, member gopas.SafeMemberResponse) bool {
+	if d.Get("exclude_predefined_members").(bool) && member.GetIsPredefinedUser() {
+		return false
+	}
+
+	for _, ignored := range d.Get("ignored_members").(*schema.Set).List() {
+		if strings.EqualFold(ignored.(string), member.GetMemberName()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// safeMembersPermissions builds the Safes API permissions of a member block.
+func safeMembersPermissions(m map[string]interface{}) map[string]bool {
+	permissions := make(map[string]bool, len(safeMemberPermissions)+2)
+	for _, permission := range safeMemberPermissions {
+		permissions[permission] = false
+	}
+	for _, attr := range m["permissions"].(*schema.Set).List() {
+		permissions[safeMemberPermissions[attr.(string)]] = true
+	}
+
+	level := m["requests_authorization_level"].(int)
+	permissions["requestsAuthorizationLevel1"] = level == 1
+	permissions["requestsAuthorizationLevel2"] = level == 2
+
+	return permissions
+}
+
+func resourceSafeMembersCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	d.SetId(d.Get("safe_name").(string))
+
+	diags := resourceSafeMembersReconcile(ctx, d, meta)
+	if diags.HasError() {
+		d.SetId("")
+		return diags
+	}
+
+	return append(diags, resourceSafeMembersRead(ctx, d, meta)...)
+}
+
+func resourceSafeMembersRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Id()
+
+	members, resp, err := listSafeMembers(ctx, client, safeName)
+	if err != nil {
+		if isNotFound(resp) {
+			d.SetId("")
+			return nil
+		}
+
+		return apiErrorDiags(resp, err)
+	}
+
+	// Keep the spelling and search_in of members as they were configured, as
+	// the API does not return search_in and may change the case of names and
+	// types.
+	configured := make(map[string]map[string]interface{})
+	for _, m := range d.Get("member").(*schema.Set).List() {
+		member := m.(map[string]interface{})
+		configured[strings.ToLower(member["member_name"].(string))] = member
+	}
+
+	result := []interface{}{}
+	for _, member := range members {
+		prior, inConfig := configured[strings.ToLower(member.GetMemberName())]
+		if !inConfig && !safeMembersManaged(d, member) {
+			continue
+		}
+
+		m := map[string]interface{}{
+			"member_name":                  member.GetMemberName(),
+			"member_type":                  member.GetMemberType(),
+			"search_in":                    "",
+			"membership_expiration_date":   int(member.GetMembershipExpirationDate()),
+			"requests_authorization_level": 0,
+		}
+		if inConfig {
+			m["member_name"] = prior["member_name"]
+			m["search_in"] = prior["search_in"]
+			if strings.EqualFold(prior["member_type"].(string), member.GetMemberType()) {
+				m["member_type"] = prior["member_type"]
+			}
+		}
+
+		apiPermissions := member.GetPermissions()
+		permissions := []interface{}{}
+		for attr, permission := range safeMemberPermissions {
+			if apiPermissions[permission] {
+				permissions = append(permissions, attr)
+			}
+		}
+		m["permissions"] = schema.NewSet(schema.HashString, permissions)
+
+		if apiPermissions["requestsAuthorizationLevel1"] {
+			m["requests_authorization_level"] = 1
+		} else if apiPermissions["requestsAuthorizationLevel2"] {
+			m["requests_authorization_level"] = 2
+		}
+
+		result = append(result, m)
+	}
+
+	d.Set("safe_name", safeName)
+	d.Set("member", result)
+
+	return nil
+}
+
+func resourceSafeMembersUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	diags := resourceSafeMembersReconcile(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, resourceSafeMembersRead(ctx, d, meta)...)
+}
+
+// resourceSafeMembersReconcile adds, updates and removes members of the safe
+// until its managed members match the configured member blocks.
+func resourceSafeMembersReconcile(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Id()
+
+	members, resp, err := listSafeMembers(ctx, client, safeName)
+	if err != nil {
+		return apiErrorDiags(resp, err)
+	}
+
+	existing := make(map[string]gopas.SafeMemberResponse, len(members))
+	for _, member := range members {
+		existing[strings.ToLower(member.GetMemberName())] = member
+	}
+
+	path := fmt.Sprintf("/api/Safes/%s/Members", url.PathEscape(safeName))
+	desired := make(map[string]bool)
+
+	for _, m := range d.Get("member").(*schema.Set).List() {
+		member := m.(map[string]interface{})
+		memberName := member["member_name"].(string)
+		permissions := safeMembersPermissions(member)
+		desired[strings.ToLower(memberName)] = true
+
+		var expiration *int64
+		if v := int64(member["membership_expiration_date"].(int)); v != 0 {
+			expiration = &v
+		}
+
+		memberType := member["member_type"].(string)
+
+		// The type of a member cannot be updated, so a member whose type
+		// changed is removed and added again.
+		current, ok := existing[strings.ToLower(memberName)]
+		if ok && !strings.EqualFold(current.GetMemberType(), memberType) {
+			_, resp, err := client.SafesApi.SafesDeleteSafeMember(ctx, safeName, current.GetMemberName()).Execute()
+			if err != nil {
+				return apiErrorDiags(resp, err)
+			}
+			ok = false
+		}
+
+		if !ok {
+			add := addSafeMemberRequest{
+				MemberName:               memberName,
+				MemberType:               memberType,
+				MembershipExpirationDate: expiration,
+				Permissions:              permissions,
+			}
+			if searchIn := member["search_in"].(string); searchIn != "" {
+				add.SearchIn = &searchIn
+			}
+
+			resp, err := apiRequest(ctx, client, http.MethodPost, path, nil, add, nil)
+			if err != nil {
+				return apiErrorDiags(resp, err)
+			}
+			continue
+		}
+
+		if safeMemberInSync(current, permissions, expiration) {
+			continue
+		}
+
+		update := updateSafeMemberRequest{MembershipExpirationDate: expiration, Permissions: permissions}
+
+		resp, err := updateSafeMember(ctx, client, safeName, current.GetMemberName(), update)
+		if err != nil {
+			return apiErrorDiags(resp, err)
+		}
+	}
+
+	removed := []string{}
+	removesUser := false
+	for _, member := range members {
+		if desired[strings.ToLower(member.GetMemberName())] || !safeMembersManaged(d, member) {
+			continue
+		}
+		removed = append(removed, member.GetMemberName())
+		removesUser = removesUser || strings.EqualFold(member.GetMemberType(), "User")
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	// Remove the user Terraform is logged on as last, as it may lose the
+	// permission to manage the members of the safe once it is removed.
+	logonUser, diags := safeMembersLogonUser(ctx, client, safeName, removesUser)
+	sort.SliceStable(removed, func(i, j int) bool {
+		return !strings.EqualFold(removed[i], logonUser) && strings.EqualFold(removed[j], logonUser)
+	})
+
+	for _, memberName := range removed {
+		_, resp, err := client.SafesApi.SafesDeleteSafeMember(ctx, safeName, memberName).Execute()
+		if err != nil {
+			return append(diags, apiErrorDiags(resp, err)...)
+		}
+	}
+
+	return diags
+}
+
+// safeMembersLogonUser returns the name of the Vault user the provider is
+// logged on as, when removing users from a safe could lock it out. It is only
+// looked up if removesUser is set. The lookup uses the legacy Logged on User
+// API, which is not available everywhere, for example on Privileged Cloud; if
+// it fails, an empty name is returned with a warning.
+func safeMembersLogonUser(ctx context.Context, client gopas.APIClient, safeName string, removesUser bool) (string, diag.Diagnostics) {
+	if !removesUser {
+		return "", nil
+	}
+
+	var user struct {
+		UserName string `json:"UserName"`
+	}
+
+	resp, err := apiRequest(ctx, client, http.MethodGet, "/WebServices/PIMServices.svc/User", nil, nil, &user)
+	if err != nil {
+		return "", diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Could not look up the logon user",
+			Detail:   "The user Terraform is logged on as could not be looked up, so it may have been removed from the safe " + safeName + " like any other member: " + newAPIError(resp, err).Error(),
+		}}
+	}
+
+	return user.UserName, nil
+}
+
+// safeMemberInSync reports whether a member already has the given permissions
+// and membership expiration date.
+func safeMemberInSync(member gopas.SafeMemberResponse, permissions map[string]bool, expiration *int64) bool {
+	current := member.GetPermissions()
+	for permission, want := range permissions {
+		if current[permission] != want {
+			return false
+		}
+	}
+
+	if expiration == nil {
+		return member.MembershipExpirationDate == nil || member.GetMembershipExpirationDate() == 0
+	}
+
+	return member.GetMembershipExpirationDate() == *expiration
+}
+
+func resourceSafeMembersDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient).Client
+
+	safeName := d.Id()
+
+	members := d.Get("member").(*schema.Set).List()
+
+	removesUser := false
+	for _, m := range members {
+		removesUser = removesUser || strings.EqualFold(m.(map[string]interface{})["member_type"].(string), "User")
+	}
+
+	// The user Terraform is logged on as is left in the safe, so that it can
+	// still manage the safe, for example to delete it.
+	logonUser, diags := safeMembersLogonUser(ctx, client, safeName, removesUser)
+
+	for _, m := range members {
+		memberName := m.(map[string]interface{})["member_name"].(string)
+		if logonUser != "" && strings.EqualFold(memberName, logonUser) {
+			continue
+		}
+
+		_, resp, err := client.SafesApi.SafesDeleteSafeMember(ctx, safeName, memberName).Execute()
+		if err != nil {
+			if isNotFound(resp) {
+				continue
+			}
+
+			return append(diags, apiErrorDiags(resp, err)...)
+		}
+	}
+
+	d.SetId("")
+
+	return diags
+}
+
+func resourceSafeMembersImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("safe_name", d.Id())
+	d.Set("exclude_predefined_members", true)
+
+	return []*schema.ResourceData{d}, nil
+}
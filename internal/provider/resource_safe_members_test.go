@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/umich-vci/gopas"
+)
+
+func TestSafeMemberInSync(t *testing.T) {
+	expiration := int64(1700000000)
+
+	member := *gopas.NewSafeMemberResponse()
+	member.SetPermissions(map[string]bool{"listAccounts": true, "useAccounts": false})
+
+	if !safeMemberInSync(member, map[string]bool{"listAccounts": true, "useAccounts": false}, nil) {
+		t.Error("expected member with matching permissions to be in sync")
+	}
+	if safeMemberInSync(member, map[string]bool{"listAccounts": true, "useAccounts": true}, nil) {
+		t.Error("expected member with missing permission to be out of sync")
+	}
+	if safeMemberInSync(member, map[string]bool{"listAccounts": true}, &expiration) {
+		t.Error("expected member without expiration to be out of sync")
+	}
+
+	member.SetMembershipExpirationDate(expiration)
+	if !safeMemberInSync(member, map[string]bool{"listAccounts": true}, &expiration) {
+		t.Error("expected member with matching expiration to be in sync")
+	}
+}
+
+func TestAccResourceSafeMembers(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceSafeMembers(safeName, "Group", `["list_accounts"]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_members.test", "member.#", "2"),
+				),
+			},
+			{
+				Config: testAccResourceSafeMembers(safeName, "Group", `["list_accounts", "use_accounts"]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_members.test", "member.#", "2"),
+				),
+			},
+			{
+				Config: testAccResourceSafeMembers(safeName, "Role", `["list_accounts", "use_accounts"]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe_members.test", "member.#", "2"),
+					resource.TestCheckTypeSetElemNestedAttrs("pas_safe_members.test", "member.*", map[string]string{
+						"member_name": "Auditors",
+						"member_type": "Role",
+					}),
+				),
+			},
+			{
+				Config:   testAccResourceSafeMembers(safeName, "role", `["list_accounts", "use_accounts"]`),
+				PlanOnly: true,
+			},
+			{
+				ResourceName:            "pas_safe_members.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"ignored_members", "member"},
+			},
+		},
+	})
+}
+
+func TestResourceSafeMembersDeleteKeepsLogonUser(t *testing.T) {
+	deleted := []string{}
+	client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/PasswordVault/WebServices/PIMServices.svc/User":
+			fmt.Fprint(w, `{"UserName":"terraform"}`)
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	d := resourceSafeMembers().TestResourceData()
+	d.SetId("Linux")
+	d.Set("member", []interface{}{
+		map[string]interface{}{"member_name": "Terraform", "member_type": "User"},
+		map[string]interface{}{"member_name": "Auditors", "member_type": "Group"},
+	})
+
+	if diags := resourceSafeMembersDelete(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	want := []string{"/PasswordVault/api/Safes/Linux/members/Auditors"}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted %v, want %v", deleted, want)
+	}
+}
+
+func TestResourceSafeMembersDeleteLogonUserUnavailable(t *testing.T) {
+	deleted := []string{}
+	client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	d := resourceSafeMembers().TestResourceData()
+	d.SetId("Linux")
+	d.Set("member", []interface{}{
+		map[string]interface{}{"member_name": "Operators", "member_type": "User"},
+	})
+
+	diags := resourceSafeMembersDelete(context.Background(), d, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("diagnostics %v, want a single warning", diags)
+	}
+
+	want := []string{"/PasswordVault/api/Safes/Linux/members/Operators"}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted %v, want %v", deleted, want)
+	}
+}
+
+func TestResourceSafeMembersDeleteGroupsSkipsLogonUser(t *testing.T) {
+	client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/PasswordVault/WebServices/PIMServices.svc/User" {
+			t.Errorf("unexpected logon user lookup")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	d := resourceSafeMembers().TestResourceData()
+	d.SetId("Linux")
+	d.Set("member", []interface{}{
+		map[string]interface{}{"member_name": "Auditors", "member_type": "Group"},
+	})
+
+	if diags := resourceSafeMembersDelete(context.Background(), d, client); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+}
+
+func testAccResourceSafeMembers(safeName, auditorsType, permissions string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  number_of_days_retention = 7
+}
+
+resource "pas_safe_members" "test" {
+  safe_name = pas_safe.test.safe_name
+
+  member {
+    member_name = "Auditors"
+    member_type = %[2]q
+    permissions = %[3]s
+  }
+
+  member {
+    member_name = %[4]q
+    permissions = ["list_accounts", "manage_safe", "manage_safe_members", "view_safe_members"]
+  }
+}
+`, safeName, auditorsType, permissions, os.Getenv("PAS_USERNAME"))
+}
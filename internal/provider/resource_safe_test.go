@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccResourceSafe(t *testing.T) {
+	safeName := "tf" + acctest.RandString(10)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV5ProviderFactories: protoV5ProviderFactories,
+		CheckDestroy:             testAccCheckSafeDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceSafe(safeName, "Created by Terraform"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe.test", "safe_name", safeName),
+					resource.TestCheckResourceAttr("pas_safe.test", "description", "Created by Terraform"),
+					resource.TestCheckResourceAttr("pas_safe.test", "number_of_days_retention", "7"),
+					resource.TestCheckResourceAttrSet("pas_safe.test", "safe_number"),
+				),
+			},
+			{
+				Config: testAccResourceSafe(safeName, "Updated by Terraform"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("pas_safe.test", "description", "Updated by Terraform"),
+				),
+			},
+			{
+				ResourceName:      "pas_safe.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestResourceSafeDeletePendingRetention(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		body        string
+		wantID      string
+		wantWarning bool
+	}{
+		{
+			name:        "retention",
+			body:        `{"ErrorCode":"ITATS528E","ErrorMessage":"Safe Linux will be deleted after the retention period of its files."}`,
+			wantWarning: true,
+		},
+		{
+			name:   "conflict mentioning retention",
+			body:   `{"ErrorCode":"PASWS027E","ErrorMessage":"Safe Linux cannot be deleted because it contains accounts within their retention period."}`,
+			wantID: "Linux",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, c.body)
+			})
+
+			d := resourceSafe().TestResourceData()
+			d.SetId("Linux")
+			d.Set("safe_name", "Linux")
+
+			diags := resourceSafeDelete(context.Background(), d, client)
+			if d.Id() != c.wantID {
+				t.Errorf("got ID %q, want %q", d.Id(), c.wantID)
+			}
+			if c.wantWarning && (len(diags) != 1 || diags.HasError()) {
+				t.Errorf("expected a warning, got %v", diags)
+			}
+			if !c.wantWarning && !diags.HasError() {
+				t.Errorf("expected an error, got %v", diags)
+			}
+		})
+	}
+}
+
+func testAccCheckSafeDestroy(s *terraform.State) error {
+	client := testAccProvider().Meta().(*apiClient).Client
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "pas_safe" {
+			continue
+		}
+
+		_, resp, err := client.SafesApi.SafesGetSafeDetails(context.Background(), rs.Primary.ID).Execute()
+		if err == nil {
+			return fmt.Errorf("safe %s still exists", rs.Primary.ID)
+		}
+		if resp == nil || resp.StatusCode != 404 {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testAccResourceSafe(name, description string) string {
+	return fmt.Sprintf(`
+resource "pas_safe" "test" {
+  safe_name                = %[1]q
+  description              = %[2]q
+  number_of_days_retention = 7
+}
+`, name, description)
+}
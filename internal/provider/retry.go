@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryTransport is an http.RoundTripper that retries requests failing with a
+// transient error: a network error, throttling, or a load balancer that could
+// not reach a healthy PVWA. Only requests that are safe to send again are
+// retried: idempotent requests, and creates marked with withDetectableCreate.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// retryStatus lists the statuses retried. 500 Internal Server Error is not
+// retried, since the PVWA also returns it for requests it rejects, such as a
+// RADIUS challenge.
+var retryStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	create, _ := req.Context().Value(detectableCreateKey{}).(*detectableCreate)
+	retryable := isIdempotent(req.Method) || create != nil
+
+	// The request can only be sent again if its body can be read again.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retryable = false
+	}
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if !retryable || attempt >= t.maxRetries {
+			return resp, err
+		}
+		if err == nil && !retryStatus[resp.StatusCode] {
+			return resp, nil
+		}
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return resp, err
+		}
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		if err != nil {
+			log.Printf("[DEBUG] retrying %s %s in %s: %s", req.Method, req.URL.Path, wait, err)
+		} else {
+			log.Printf("[DEBUG] retrying %s %s in %s: %s", req.Method, req.URL.Path, wait, resp.Status)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if create != nil {
+			create.retried = true
+		}
+	}
+}
+
+// backoff returns how long to wait before sending a request again. The
+// Retry-After header of resp is honored up to maxBackoff, so that a server
+// cannot hold a request indefinitely; otherwise the wait grows exponentially
+// from minBackoff up to maxBackoff, with jitter so that concurrent requests
+// are spread out.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > t.maxBackoff {
+				wait = t.maxBackoff
+			}
+			return wait
+		}
+	}
+
+	wait := t.minBackoff << attempt
+	if wait > t.maxBackoff || wait <= 0 {
+		wait = t.maxBackoff
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as a
+// date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(v); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+type detectableCreateKey struct{}
+
+// detectableCreate records whether a create request was sent more than once.
+type detectableCreate struct {
+	retried bool
+}
+
+// withDetectableCreate marks the requests sent with ctx as creates that may be
+// retried, because the caller detects when a retry is rejected as a duplicate
+// of an attempt that succeeded.
+func withDetectableCreate(ctx context.Context) (context.Context, *detectableCreate) {
+	create := &detectableCreate{}
+	return context.WithValue(ctx, detectableCreateKey{}, create), create
+}
+
+// duplicated reports whether a create failed with resp only because an
+// earlier attempt of the same create succeeded.
+func (c *detectableCreate) duplicated(resp *http.Response) bool {
+	return c.retried && resp != nil && resp.StatusCode == http.StatusConflict
+}
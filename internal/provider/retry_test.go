@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		name         string
+		method       string
+		create       bool
+		statuses     []int
+		wantAttempts int32
+		wantStatus   int
+		wantRetried  bool
+	}{
+		{name: "get recovers", method: http.MethodGet, statuses: []int{503, 502, 200}, wantAttempts: 3, wantStatus: 200},
+		{name: "get gives up", method: http.MethodGet, statuses: []int{503, 503, 503, 503, 200}, wantAttempts: 4, wantStatus: 503},
+		{name: "get internal server error", method: http.MethodGet, statuses: []int{500, 200}, wantAttempts: 1, wantStatus: 500},
+		{name: "put throttled", method: http.MethodPut, statuses: []int{429, 200}, wantAttempts: 2, wantStatus: 200},
+		{name: "post", method: http.MethodPost, statuses: []int{503, 201}, wantAttempts: 1, wantStatus: 503},
+		{name: "patch", method: http.MethodPatch, statuses: []int{504, 200}, wantAttempts: 1, wantStatus: 504},
+		{name: "detectable create", method: http.MethodPost, create: true, statuses: []int{504, 201}, wantAttempts: 2, wantStatus: 201, wantRetried: true},
+		{name: "detectable create duplicated", method: http.MethodPost, create: true, statuses: []int{504, 409}, wantAttempts: 2, wantStatus: 409, wantRetried: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				if b, _ := io.ReadAll(r.Body); string(b) != "body" {
+					t.Errorf("attempt %d got body %q", n, b)
+				}
+				w.WriteHeader(c.statuses[n-1])
+			}))
+			defer server.Close()
+
+			ctx := context.Background()
+			var create *detectableCreate
+			if c.create {
+				ctx, create = withDetectableCreate(ctx)
+			}
+
+			req, _ := http.NewRequestWithContext(ctx, c.method, server.URL, strings.NewReader("body"))
+			transport := &retryTransport{base: http.DefaultTransport, maxRetries: 3, minBackoff: time.Millisecond, maxBackoff: 2 * time.Millisecond}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if attempts != c.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, c.wantAttempts)
+			}
+			if resp.StatusCode != c.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, c.wantStatus)
+			}
+			if create != nil && create.retried != c.wantRetried {
+				t.Errorf("got retried %v, want %v", create.retried, c.wantRetried)
+			}
+			if create != nil && create.duplicated(resp) != (c.wantStatus == http.StatusConflict) {
+				t.Errorf("got duplicated %v", create.duplicated(resp))
+			}
+		})
+	}
+}
+
+func TestRetryTransportDeleteResponseLost(t *testing.T) {
+	var attempts int32
+	client := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&attempts, 1) > 1 {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"ErrorCode":"PASWS013E","ErrorMessage":"Account 1_1 was not found."}`)
+			return
+		}
+
+		// The account is deleted, but the response never reaches the client.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+	client.Client.GetConfig().HTTPClient = &http.Client{
+		Transport: &retryTransport{base: http.DefaultTransport, maxRetries: 3, minBackoff: time.Millisecond, maxBackoff: 2 * time.Millisecond},
+	}
+
+	d := resourceAccount().TestResourceData()
+	d.SetId("1_1")
+
+	if diags := resourceAccountDelete(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+	if attempts < 2 {
+		t.Errorf("got %d attempts, want the deletion to be retried", attempts)
+	}
+	if d.Id() != "" {
+		t.Errorf("got ID %q, want the account removed from the state", d.Id())
+	}
+}
+
+func TestRetryTransportCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	transport := &retryTransport{base: http.DefaultTransport, maxRetries: 3, minBackoff: time.Millisecond, maxBackoff: time.Hour}
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("expected an error when the context is canceled while waiting")
+	}
+}
+
+func TestRetryTransportBackoff(t *testing.T) {
+	transport := &retryTransport{minBackoff: time.Second, maxBackoff: 10 * time.Second}
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		wait := transport.backoff(attempt, nil)
+		if wait < max/2 || wait > max {
+			t.Errorf("attempt %d: got backoff %s, want between %s and %s", attempt, wait, max/2, max)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"7"}}}
+	if wait := transport.backoff(0, resp); wait != 7*time.Second {
+		t.Errorf("got backoff %s for Retry-After in seconds", wait)
+	}
+
+	resp.Header.Set("Retry-After", time.Now().Add(9*time.Second).UTC().Format(http.TimeFormat))
+	if wait := transport.backoff(0, resp); wait < 7*time.Second || wait > 9*time.Second {
+		t.Errorf("got backoff %s for Retry-After as a date", wait)
+	}
+
+	resp.Header.Set("Retry-After", "86400")
+	if wait := transport.backoff(0, resp); wait != 10*time.Second {
+		t.Errorf("got backoff %s for a Retry-After longer than maxBackoff, want 10s", wait)
+	}
+}
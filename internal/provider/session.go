@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/umich-vci/gopas"
+)
+
+// sessionAuthenticator is implemented by the authenticators whose tokens are
+// PVWA sessions. Sessions count against the concurrent session license until
+// they are logged off or time out.
+type sessionAuthenticator interface {
+	authenticator
+	logoff(ctx context.Context, client *gopas.APIClient) error
+}
+
+// session is the http.RoundTripper of the client used by resources and data
+// sources. It adds the Authorization header to every request, and when the
+// PVWA responds with 401 Unauthorized, because the session has timed out, it
+// authenticates again and retries the request once. Concurrent requests share
+// the session, so only one of them authenticates again.
+type session struct {
+	base   http.RoundTripper
+	auth   authenticator
+	client *gopas.APIClient
+
+	mu     sync.RWMutex
+	token  string
+	closed bool
+}
+
+// errSessionClosed is returned when authenticating after the session has been
+// logged off.
+var errSessionClosed = errors.New("the session has been logged off")
+
+// newSession returns a session that authenticates with auth using client,
+// whose HTTP client must not be the session itself.
+func newSession(auth authenticator, client *gopas.APIClient) *session {
+	base := client.GetConfig().HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &session{
+		base:   base,
+		auth:   auth,
+		client: client,
+	}
+}
+
+// open authenticates, replacing the token of the session if it is still
+// stale. stale is the token a request was rejected with, so that concurrent
+// requests rejected with the same token only authenticate once.
+func (s *session) open(ctx context.Context, stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return "", errSessionClosed
+	}
+	if s.token != stale {
+		return s.token, nil
+	}
+
+	token, err := s.auth.authenticate(ctx, s.client)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+
+	return token, nil
+}
+
+func (s *session) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.RLock()
+	token := s.token
+	s.mu.RUnlock()
+
+	resp, err := s.base.RoundTrip(withAuthorization(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The request can only be sent again if its body can be read again.
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	token, err = s.open(req.Context(), token)
+	if errors.Is(err, errSessionClosed) {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("authenticating again after the session expired: %w", err)
+	}
+
+	retry := withAuthorization(req, token)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+
+	return s.base.RoundTrip(retry)
+}
+
+// withAuthorization returns a copy of req with the Authorization header set to
+// token, since a RoundTripper must not modify the request it is given.
+func withAuthorization(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", token)
+	return r
+}
+
+// close logs off the session if its authenticator opened a PVWA session. The
+// session cannot authenticate again afterwards. api is the client that sends
+// requests through the session.
+func (s *session) close(ctx context.Context, api *gopas.APIClient) error {
+	s.mu.Lock()
+	closed, token := s.closed, s.token
+	s.closed = true
+	s.mu.Unlock()
+
+	auth, ok := s.auth.(sessionAuthenticator)
+	if closed || !ok || token == "" {
+		return nil
+	}
+
+	return auth.logoff(ctx, api)
+}
+
+var (
+	openSessionsMu sync.Mutex
+	openSessions   []*apiClient
+)
+
+// Logoff logs off the PVWA sessions opened by the provider in this process.
+// It is called when the provider shuts down, so that sessions do not count
+// against the concurrent session license until they time out.
+func Logoff(ctx context.Context) error {
+	openSessionsMu.Lock()
+	clients := openSessions
+	openSessions = nil
+	openSessionsMu.Unlock()
+
+	var errs []error
+	for _, c := range clients {
+		if err := c.session.close(ctx, &c.Client); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// registerSession records a configured client, so that Logoff can log off its
+// session.
+func registerSession(c *apiClient) {
+	openSessionsMu.Lock()
+	defer openSessionsMu.Unlock()
+
+	openSessions = append(openSessions, c)
+}
+
+// logoffSession logs off the PVWA session that client authenticates with.
+func logoffSession(ctx context.Context, client *gopas.APIClient) error {
+	_, err := apiRequest(ctx, *client, http.MethodPost, "/api/Auth/Logoff", nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("logging off: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/umich-vci/gopas"
+)
+
+// testSessionServer is a PVWA that issues a new session token at every logon
+// and expires the current one when expire is called.
+type testSessionServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	valid   string
+	logons  int32
+	logoffs []string
+}
+
+func newTestSessionServer(t *testing.T) *testSessionServer {
+	s := &testSessionServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.HasSuffix(r.URL.Path, "/Logon") {
+			n := atomic.AddInt32(&s.logons, 1)
+			s.mu.Lock()
+			s.valid = fmt.Sprintf("token-%d", n)
+			s.mu.Unlock()
+			fmt.Fprintf(w, "%q", s.valid)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.Header.Get("Authorization") != s.valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"ErrorCode":"PASWS002E","ErrorMessage":"Session token is missing or expired."}`)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/Auth/Logoff") {
+			s.logoffs = append(s.logoffs, s.valid)
+			s.valid = ""
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testSessionServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valid = ""
+}
+
+// testSessionClient returns a client that authenticates with auth through a
+// session, set up as configure does.
+func testSessionClient(t *testing.T, serverURL string, auth authenticator) *apiClient {
+	u, _ := url.Parse(serverURL)
+	config := gopas.NewConfiguration()
+	config.Host = u.Host
+	config.Scheme = u.Scheme
+
+	logonConfig := *config
+	logonConfig.HTTPClient = &http.Client{}
+	s := newSession(auth, gopas.NewAPIClient(&logonConfig))
+	if _, err := s.open(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	config.HTTPClient = &http.Client{Transport: s}
+	return &apiClient{Client: *gopas.NewAPIClient(config), session: s}
+}
+
+func TestSessionReauthenticates(t *testing.T) {
+	server := newTestSessionServer(t)
+	c := testSessionClient(t, server.URL, &logonAuthenticator{username: "u", password: "p", authType: "cyberark"})
+
+	server.expire()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := apiRequest(context.Background(), c.Client, http.MethodPost, "/api/Accounts", nil, map[string]string{"name": "a"}, nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := atomic.LoadInt32(&server.logons); n != 2 {
+		t.Errorf("got %d logons, want 2", n)
+	}
+}
+
+func TestSessionLogoff(t *testing.T) {
+	server := newTestSessionServer(t)
+	c := testSessionClient(t, server.URL, &logonAuthenticator{username: "u", password: "p", authType: "cyberark"})
+
+	if err := c.session.close(context.Background(), &c.Client); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.logoffs) != 1 || server.logoffs[0] != "token-1" {
+		t.Errorf("got logoffs %v, want [token-1]", server.logoffs)
+	}
+
+	// A closed session does not log on again.
+	if _, err := apiRequest(context.Background(), c.Client, http.MethodGet, "/api/Safes", nil, nil, nil); err == nil {
+		t.Error("expected an error after logging off")
+	}
+	if n := atomic.LoadInt32(&server.logons); n != 1 {
+		t.Errorf("got %d logons, want 1", n)
+	}
+	if err := c.session.close(context.Background(), &c.Client); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.logoffs) != 1 {
+		t.Errorf("logged off %d times, want 1", len(server.logoffs))
+	}
+}
+
+func TestSessionLogoffExternalToken(t *testing.T) {
+	server := newTestSessionServer(t)
+	c := testSessionClient(t, server.URL, &tokenAuthenticator{token: "external"})
+
+	if err := c.session.close(context.Background(), &c.Client); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.logoffs) != 0 {
+		t.Errorf("logged off a token issued outside of the provider")
+	}
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// newHTTPClient returns the HTTP client used for every request to the PVWA,
+// with the TLS, proxy, timeout and retry settings of the provider
+// configuration.
+func newHTTPClient(d *schema.ResourceData) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: d.Get("insecure_skip_verify").(bool),
+	}
+
+	connectTimeout := time.Duration(d.Get("connect_timeout").(int)) * time.Second
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+
+	if v := d.Get("proxy_url").(string); v != "" {
+		proxy, err := url.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy_url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	roots, err := rootCAs(d)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig.RootCAs = roots
+
+	cert, err := clientCertificate(d)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			base:       transport,
+			maxRetries: d.Get("max_retries").(int),
+			minBackoff: time.Duration(d.Get("retry_min_backoff").(int)) * time.Second,
+			maxBackoff: time.Duration(d.Get("retry_max_backoff").(int)) * time.Second,
+		},
+		Timeout: time.Duration(d.Get("request_timeout").(int)) * time.Second,
+	}, nil
+}
+
+// rootCAs returns the system certificate authorities together with the ones
+// from the provider configuration. It returns nil, so that only the system
+// certificate authorities are trusted, if none are configured.
+func rootCAs(d *schema.ResourceData) (*x509.CertPool, error) {
+	caPEM, err := pemArgument(d, "ca_cert_pem", "ca_cert_file")
+	if err != nil || caPEM == nil {
+		return nil, err
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in the CA certificates")
+	}
+
+	return roots, nil
+}
+
+// serverURL returns the URL of the PVWA for the gopas configuration. Only the
+// path is used, as the host is overridden by pas_host.
+func serverURL(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return "https://pas.example.com"
+	}
+	return "https://pas.example.com/" + basePath
+}
+
+// clientCertificate loads the client certificate and key from the provider
+// configuration. It returns nil if no client certificate is configured.
+func clientCertificate(d *schema.ResourceData) (*tls.Certificate, error) {
+	certPEM, err := pemArgument(d, "client_cert_pem", "client_cert_file")
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := pemArgument(d, "client_key_pem", "client_key_file")
+	if err != nil {
+		return nil, err
+	}
+
+	if certPEM == nil && keyPEM == nil {
+		return nil, nil
+	}
+	if certPEM == nil || keyPEM == nil {
+		return nil, fmt.Errorf("a client certificate requires both a certificate and a key")
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %w", err)
+	}
+
+	return &cert, nil
+}
+
+// pemArgument returns the PEM given inline in pemAttr or read from the file
+// named by fileAttr. At most one of them may be set.
+func pemArgument(d *schema.ResourceData, pemAttr, fileAttr string) ([]byte, error) {
+	inline := d.Get(pemAttr).(string)
+	file := d.Get(fileAttr).(string)
+
+	switch {
+	case inline != "" && file != "":
+		return nil, fmt.Errorf("only one of %s and %s can be set", pemAttr, fileAttr)
+	case inline != "":
+		return []byte(inline), nil
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", fileAttr, err)
+		}
+		return b, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/umich-vci/gopas"
+)
+
+// testClientCertificate returns a self-signed client certificate and its key,
+// PEM encoded.
+func testClientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "terraform"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM)
+}
+
+func TestClientCertificate(t *testing.T) {
+	certPEM, keyPEM := testClientCertificate(t)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, []byte(certPEM), 0600)
+	os.WriteFile(keyFile, []byte(keyPEM), 0600)
+
+	cases := []struct {
+		name     string
+		raw      map[string]interface{}
+		wantCert bool
+		wantErr  bool
+	}{
+		{name: "none", raw: map[string]interface{}{}},
+		{name: "inline", raw: map[string]interface{}{"client_cert_pem": certPEM, "client_key_pem": keyPEM}, wantCert: true},
+		{name: "files", raw: map[string]interface{}{"client_cert_file": certFile, "client_key_file": keyFile}, wantCert: true},
+		{name: "mixed", raw: map[string]interface{}{"client_cert_file": certFile, "client_key_pem": keyPEM}, wantCert: true},
+		{name: "file and inline", raw: map[string]interface{}{"client_cert_file": certFile, "client_cert_pem": certPEM, "client_key_pem": keyPEM}, wantErr: true},
+		{name: "missing key", raw: map[string]interface{}{"client_cert_pem": certPEM}, wantErr: true},
+		{name: "missing file", raw: map[string]interface{}{"client_cert_file": filepath.Join(dir, "missing.crt"), "client_key_pem": keyPEM}, wantErr: true},
+		{name: "invalid", raw: map[string]interface{}{"client_cert_pem": keyPEM, "client_key_pem": keyPEM}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("PAS_CLIENT_CERT_FILE", "")
+			t.Setenv("PAS_CLIENT_KEY_FILE", "")
+
+			d := schema.TestResourceDataRaw(t, New("dev")().Schema, c.raw)
+			cert, err := clientCertificate(d)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (cert != nil) != c.wantCert {
+				t.Errorf("got certificate %v, want %v", cert != nil, c.wantCert)
+			}
+		})
+	}
+}
+
+func TestPKILogon(t *testing.T) {
+	certPEM, keyPEM := testClientCertificate(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/PasswordVault/api/Auth/pki/Logon" || len(r.TLS.PeerCertificates) == 0 || body["UserName"] != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "%q", "token-for-"+r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	t.Setenv("PAS_CLIENT_CERT_FILE", "")
+	t.Setenv("PAS_CLIENT_KEY_FILE", "")
+	t.Setenv("PAS_CA_CERT_FILE", "")
+	d := schema.TestResourceDataRaw(t, New("dev")().Schema, map[string]interface{}{
+		"auth_type":       "pki",
+		"client_cert_pem": certPEM,
+		"client_key_pem":  keyPEM,
+		"ca_cert_pem":     testServerCA(server),
+	})
+
+	httpClient, err := newHTTPClient(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(server.URL)
+	config := gopas.NewConfiguration()
+	config.Host = u.Host
+	config.HTTPClient = httpClient
+	client := gopas.NewAPIClient(config)
+
+	a, err := newAuthenticator(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := a.authenticate(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-for-terraform" {
+		t.Errorf("got token %q", token)
+	}
+}
+
+// testServerCA returns the certificate of a TLS test server, PEM encoded.
+func testServerCA(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, []byte(testServerCA(server)), 0600)
+
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr bool
+	}{
+		{name: "system roots", raw: map[string]interface{}{}, wantErr: true},
+		{name: "ca_cert_pem", raw: map[string]interface{}{"ca_cert_pem": testServerCA(server)}},
+		{name: "ca_cert_file", raw: map[string]interface{}{"ca_cert_file": caFile}},
+		{name: "insecure_skip_verify", raw: map[string]interface{}{"insecure_skip_verify": true}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("PAS_CA_CERT_FILE", "")
+			t.Setenv("PAS_INSECURE_SKIP_VERIFY", "")
+
+			d := schema.TestResourceDataRaw(t, New("dev")().Schema, c.raw)
+			httpClient, err := newHTTPClient(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := httpClient.Get(server.URL)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected a certificate verification error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestNewHTTPClientSettings(t *testing.T) {
+	t.Setenv("PAS_CA_CERT_FILE", "")
+	t.Setenv("PAS_INSECURE_SKIP_VERIFY", "")
+
+	d := schema.TestResourceDataRaw(t, New("dev")().Schema, map[string]interface{}{
+		"proxy_url":       "http://proxy.example.com:3128",
+		"request_timeout": 45,
+	})
+	httpClient, err := newHTTPClient(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if httpClient.Timeout != 45*time.Second {
+		t.Errorf("got request timeout %s", httpClient.Timeout)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://cyberark.example.com/PasswordVault", nil)
+	proxy, err := httpClient.Transport.(*retryTransport).base.(*http.Transport).Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("got proxy %v", proxy)
+	}
+
+	d = schema.TestResourceDataRaw(t, New("dev")().Schema, map[string]interface{}{"ca_cert_pem": "not a certificate"})
+	if _, err := newHTTPClient(d); err == nil {
+		t.Error("expected an error for invalid CA certificates")
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	cases := map[string]string{
+		"/PasswordVault": "https://pas.example.com/PasswordVault",
+		"vault/pvwa/":    "https://pas.example.com/vault/pvwa",
+		"/":              "https://pas.example.com",
+	}
+	for basePath, want := range cases {
+		if got := serverURL(basePath); got != want {
+			t.Errorf("serverURL(%q) = %q, want %q", basePath, got, want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
+	"time"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5/tf5server"
 	"github.com/umich-vci/terraform-provider-pas/internal/provider"
 )
 
@@ -26,19 +30,41 @@ var (
 	// commit  string = ""
 )
 
+// logoffTimeout bounds how long logging off delays the shutdown of the
+// provider, since Terraform does not wait long for it to exit.
+const logoffTimeout = 2 * time.Second
+
 func main() {
 	var debugMode bool
 
 	flag.BoolVar(&debugMode, "debug", false, "set to true to run the provider with support for debuggers like delve")
 	flag.Parse()
 
-	opts := &plugin.ServeOpts{
-		Debug: debugMode,
+	server, err := provider.ProviderServer(version)()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		ProviderAddr: "registry.terraform.io/umich-vci/pas",
+	var opts []tf5server.ServeOpt
+	if debugMode {
+		opts = append(opts, tf5server.WithManagedDebug())
+	}
 
-		ProviderFunc: provider.New(version),
+	err = tf5server.Serve(
+		"registry.terraform.io/umich-vci/pas",
+		func() tfprotov5.ProviderServer { return server },
+		opts...,
+	)
+
+	// Log off the PVWA sessions opened while serving, so that they do not
+	// count against the concurrent session license until they time out.
+	ctx, cancel := context.WithTimeout(context.Background(), logoffTimeout)
+	defer cancel()
+	if err := provider.Logoff(ctx); err != nil {
+		log.Printf("[WARN] %s", err)
 	}
 
-	plugin.Serve(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
 }